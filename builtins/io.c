@@ -177,6 +177,11 @@ void vprint(const char* fmt, va_list args) {
     }
 }
 
+void vprintln(const char* fmt, va_list args) {
+    vprint(fmt, args);
+    putchar('\n');
+}
+
 void print(const char* fmt, ...) {
     va_list args;
     va_start(args, fmt);
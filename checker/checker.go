@@ -2,132 +2,1033 @@ package checker
 
 import (
 	"fmt"
+	"grianlang3/diagnostics"
 	"grianlang3/emitter"
 	"grianlang3/lexer"
 	"grianlang3/parser"
 	"grianlang3/util"
-	"regexp"
+	"os"
+	"sort"
 	"strings"
 )
 
 type Checker struct {
-	importsFound     map[string]struct{}
-	builtinNames     map[string]map[string]struct{}
-	constVars        map[string]struct{}
-	varTypes         map[string]lexer.VarType
-	structFieldTypes map[string]map[string]lexer.VarType
-	Errors           []util.PositionError
+	importsFound       map[string]struct{}
+	builtinNames       map[string]map[string]struct{}
+	constVars          map[string]struct{}
+	scopes             []map[string]*parser.Symbol
+	globals            map[string]*parser.Symbol
+	funcs              map[string]*parser.Symbol
+	structFieldIndexes map[string]map[string]int
+	structFields       map[string][]lexer.VarType
+	currFncType        lexer.VarType
+	currFncVariadic    bool
+	loopDepth          int
+	decls              []*parser.Symbol // checked for use once the whole program has been seen
+	used               map[*parser.Symbol]struct{}
+	imports            []*parser.ImportStatement
+	importOf           map[*parser.Symbol]string
+	usedImports        map[string]struct{}
+	Info               *parser.TypeInfo
+	Errors             []util.PositionError
+	Warnings           []util.PositionError
+	// Imported is whether another file of the build imports this one, whose top level functions and globals can then
+	// be used from there, so aren't reported as unused
+	Imported bool
 }
 
 func New() *Checker {
 	return &Checker{
-		builtinNames:     emitter.GetBuiltinNames(),
-		importsFound:     make(map[string]struct{}),
-		constVars:        make(map[string]struct{}),
-		varTypes:         make(map[string]lexer.VarType),
-		structFieldTypes: make(map[string]map[string]lexer.VarType),
+		builtinNames:       emitter.GetBuiltinNames(),
+		importsFound:       make(map[string]struct{}),
+		constVars:          make(map[string]struct{}),
+		globals:            make(map[string]*parser.Symbol),
+		funcs:              make(map[string]*parser.Symbol),
+		structFieldIndexes: make(map[string]map[string]int),
+		structFields:       make(map[string][]lexer.VarType),
+		used:               make(map[*parser.Symbol]struct{}),
+		importOf:           make(map[*parser.Symbol]string),
+		usedImports:        make(map[string]struct{}),
+		Info:               parser.NewTypeInfo(),
 	}
 }
 
+var boolType = lexer.VarType{Base: lexer.Bool}
+var floatType = lexer.VarType{Base: lexer.Float}
+var voidType = lexer.VarType{Base: lexer.Void}
+
+// these mirror the emitter's int tables, an operator or cast it can't lower is a type error here
+var intBases = map[lexer.BaseVarType]struct{}{
+	lexer.Int8:   {},
+	lexer.Int16:  {},
+	lexer.Int32:  {},
+	lexer.Int:    {},
+	lexer.Uint8:  {},
+	lexer.Uint16: {},
+	lexer.Uint32: {},
+	lexer.Uint:   {},
+}
+
+var signedBases = map[lexer.BaseVarType]struct{}{
+	lexer.Int8:  {},
+	lexer.Int16: {},
+	lexer.Int32: {},
+	lexer.Int:   {},
+}
+
+var castIntBases = map[lexer.BaseVarType]struct{}{
+	lexer.Bool:   {},
+	lexer.Int8:   {},
+	lexer.Int16:  {},
+	lexer.Int32:  {},
+	lexer.Int:    {},
+	lexer.Uint8:  {},
+	lexer.Uint16: {},
+	lexer.Uint32: {},
+	lexer.Uint:   {},
+}
+
 func (c *Checker) Check(node parser.Node) {
 	switch node := node.(type) {
 	case *parser.Program:
 		for _, s := range node.Statements {
 			c.Check(s)
 		}
+		c.reportUnused()
 	case *parser.FunctionStatement:
-		for _, s := range node.Body.Statements {
-			c.Check(s)
+		c.checkStructsDefined(node.Type, node.Name.Position())
+		var paramTypes []lexer.VarType
+		for _, p := range node.Params {
+			c.checkStructsDefined(p.Type, p.Name.Position())
+			paramTypes = append(paramTypes, p.Type)
 		}
-	case *parser.WhileStatement:
-		c.Check(node.Condition)
-		for _, s := range node.Body.Statements {
-			c.Check(s)
+		// known from here on, like in the emitter. before the body so recursive calls work
+		sym := &parser.Symbol{
+			Kind:     parser.FunctionSymbol,
+			Name:     node.Name.Value,
+			Type:     node.Type,
+			Params:   paramTypes,
+			Variadic: node.Variadic,
 		}
-	case *parser.IfStatement:
-		c.Check(node.Condition)
-		for _, s := range node.Success.Statements {
-			c.Check(s)
+		if !node.Extern {
+			sym.Position = node.Name.Position()
+			if prev, ok := c.funcs[node.Name.Value]; ok && prev.Position != nil {
+				c.appendRedefinition(sym, prev)
+			}
+		}
+		c.funcs[node.Name.Value] = sym
+		if node.Extern {
+			break
+		}
+		// main and exported functions are called from outside the program
+		if node.Name.Value != "main" && !node.Exported {
+			c.decls = append(c.decls, sym)
+		}
+
+		c.currFncType = node.Type
+		c.currFncVariadic = node.Variadic
+		c.pushScope()
+		for _, p := range node.Params {
+			c.declare(parser.ParameterSymbol, p.Name.Value, p.Type, p.Name.Position())
+		}
+		if c.checkStatements(node.Body.Statements) && !node.Type.Equals(voidType) {
+			c.appendError(node.Name.Position(), diagnostics.CodeMissingReturn, "missing return at end of function %s returning %s", node.Name.Value, node.Type)
 		}
+		c.popScope()
+	case *parser.WhileStatement:
+		c.checkCondition(node.Condition, "while")
+		c.loopDepth++
+		c.checkBlock(node.Body)
+		c.loopDepth--
+	case *parser.IfStatement:
+		c.checkCondition(node.Condition, "if")
+		c.checkBlock(node.Success)
 		if node.Fail != nil {
-			for _, s := range node.Fail.Statements {
-				c.Check(s)
-			}
+			c.checkBlock(node.Fail)
+		}
+	case *parser.BreakStatement:
+		if c.loopDepth == 0 {
+			c.appendError(node.Position(), diagnostics.CodeOutsideLoop, "break used outside of a while loop")
+		}
+	case *parser.ContinueStatement:
+		if c.loopDepth == 0 {
+			c.appendError(node.Position(), diagnostics.CodeOutsideLoop, "continue used outside of a while loop")
 		}
 	case *parser.ExpressionStatement:
-		c.Check(node.Expression)
+		c.checkExpr(node.Expression)
 	case *parser.ReturnStatement:
-		c.Check(node.Expr)
-	case *parser.CastExpression:
-		c.Check(node.Expr)
-	case *parser.DereferenceExpression:
-		c.Check(node.Var)
+		c.checkReturn(node)
+	case *parser.DeferStatement:
+		c.checkExpr(node.Expr)
 	case *parser.ImportStatement:
-		if !strings.HasSuffix(node.Path, ".gl3") {
-			c.importsFound[node.Path] = struct{}{}
-		}
-	case *parser.InfixExpression:
-		c.Check(node.Left)
-		c.Check(node.Right)
+		c.checkImport(node)
 	case *parser.DefStatement:
 		if node.Global && node.Constant {
-			fmt.Printf("%v\n", node)
 			c.constVars[node.Name.Value] = struct{}{}
 		}
-		c.varTypes[node.Name.Value] = node.Type
-		c.Check(node.Right)
+		c.checkStructsDefined(node.Type, node.Name.Position())
+		if !node.Extern {
+			c.checkExprAs(node.Right, node.Type, "def of "+node.Name.Value)
+			if node.Global && !isConstant(node.Right) {
+				c.appendError(node.Right.Position(), diagnostics.CodeNonConstant, "global %s must be initialized with a constant value", node.Name.Value)
+			}
+		}
+		// extern globals are owned by whatever defines them, not this program
+		pos := node.Name.Position()
+		if node.Extern {
+			pos = nil
+		}
+		kind := parser.VariableSymbol
+		if node.Global {
+			kind = parser.GlobalSymbol
+		}
+		// the emitter finds the variable's storage through the symbol of its declaration
+		c.Info.Symbols[node.Name] = c.declare(kind, node.Name.Value, node.Type, pos)
+	case *parser.DestructureStatement:
+		if vt, ok := c.checkExpr(node.Right); ok {
+			if len(vt.Tuple) != len(node.Names) {
+				c.appendError(node.Position(), diagnostics.CodeNotDestructured, "cannot destructure value of type %s into %d variables", vt, len(node.Names))
+			} else {
+				for i, name := range node.Names {
+					if !assignable(node.Types[i], vt.Tuple[i]) {
+						c.appendError(name.Position(), diagnostics.CodeTypeMismatch, "cannot use value of type %s as %s in def of %s", vt.Tuple[i], node.Types[i], name.Value)
+					}
+				}
+			}
+		}
+		for i, name := range node.Names {
+			c.checkStructsDefined(node.Types[i], name.Position())
+			c.Info.Symbols[name] = c.declare(parser.VariableSymbol, name.Value, node.Types[i], name.Position())
+		}
+	case *parser.StructStatement:
+		for _, t := range node.Types {
+			// a struct can point to itself, which is all it can hold of a type that isn't finished yet
+			if t.IsStructType && t.StructName == node.Name && t.Pointer > 0 {
+				continue
+			}
+			c.checkStructsDefined(t, node.Position())
+		}
+		c.structFieldIndexes[node.Name] = node.Names
+		c.structFields[node.Name] = node.Types
+	}
+}
+
+// isConstant is whether expr is a value the emitter can write into the module as is, which globals are initialized
+// with before the program runs: literals, sizeof, negated numbers, and structs, optionals and results of constants
+func isConstant(expr parser.Expression) bool {
+	switch expr := expr.(type) {
+	case *parser.IntegerLiteral, *parser.FloatLiteral, *parser.BooleanExpression, *parser.StringLiteral,
+		*parser.SizeofExpression, *parser.NullLiteral:
+		return true
+	case *parser.PrefixExpression:
+		return expr.Operator == "-" && isConstant(expr.Right)
+	case *parser.WrapExpression:
+		return expr.Value == nil || isConstant(expr.Value)
+	case *parser.StructInitializationExpression:
+		for _, v := range expr.Values {
+			if !isConstant(v) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// checkStructsDefined reports the struct types in vt that aren't defined, by a struct statement before it or an
+// import, as the emitter lowers types in program order
+func (c *Checker) checkStructsDefined(vt lexer.VarType, pos *util.Position) bool {
+	ok := true
+	for _, t := range vt.Tuple {
+		ok = c.checkStructsDefined(t, pos) && ok
+	}
+	if vt.ErrType != nil {
+		ok = c.checkStructsDefined(*vt.ErrType, pos) && ok
+	}
+	if _, defined := c.structFields[vt.StructName]; vt.IsStructType && !defined {
+		c.appendError(pos, diagnostics.CodeUndefinedStruct, "undefined struct %s", vt.StructName)
+		return false
+	}
+	return ok
+}
+
+func (c *Checker) appendError(pos *util.Position, code string, msg string, args ...any) {
+	c.Errors = append(c.Errors, util.PositionError{
+		Position: pos,
+		Code:     code,
+		Msg:      fmt.Sprintf(msg, args...),
+	})
+}
+
+func (c *Checker) appendWarning(pos *util.Position, code string, msg string, args ...any) {
+	c.Warnings = append(c.Warnings, util.PositionError{
+		Position: pos,
+		Code:     code,
+		Msg:      fmt.Sprintf(msg, args...),
+	})
+}
+
+func (c *Checker) appendRedefinition(sym, prev *parser.Symbol) {
+	c.Errors = append(c.Errors, util.PositionError{
+		Position: sym.Position,
+		Code:     diagnostics.CodeRedefinition,
+		Msg:      fmt.Sprintf("%s %s is already defined", sym.Kind, sym.Name),
+		Related:  []util.Related{{Position: prev.Position, Msg: "previously defined here"}},
+	})
+}
+
+func (c *Checker) pushScope() {
+	c.scopes = append(c.scopes, make(map[string]*parser.Symbol))
+}
+
+func (c *Checker) popScope() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+// declare adds name to the innermost scope, or the globals. pos is where it was declared, nil for names that came
+// from elsewhere, which aren't reported when unused or redefined
+func (c *Checker) declare(kind parser.SymbolKind, name string, vt lexer.VarType, pos *util.Position) *parser.Symbol {
+	sym := &parser.Symbol{Kind: kind, Name: name, Type: vt, Position: pos}
+	scope := c.globals
+	if kind == parser.GlobalSymbol || len(c.scopes) == 0 {
+		sym.Kind = parser.GlobalSymbol
+	} else {
+		scope = c.scopes[len(c.scopes)-1]
+	}
+	if pos != nil {
+		c.decls = append(c.decls, sym)
+		if prev, ok := scope[name]; ok && prev.Position != nil {
+			c.appendRedefinition(sym, prev)
+		}
+	}
+	scope[name] = sym
+	return sym
+}
+
+// use marks sym as used, along with the import it came from
+func (c *Checker) use(sym *parser.Symbol) {
+	c.used[sym] = struct{}{}
+	if path, ok := c.importOf[sym]; ok {
+		c.usedImports[path] = struct{}{}
+	}
+}
+
+// reportUnused warns about every declaration and import that nothing referenced. names starting with _ are left
+// alone, as is main, and so are the top level declarations of an imported file
+func (c *Checker) reportUnused() {
+	var unused []util.PositionError
+	for _, sym := range c.decls {
+		if _, ok := c.used[sym]; ok || strings.HasPrefix(sym.Name, "_") {
+			continue
+		}
+		if c.Imported && (sym.Kind == parser.FunctionSymbol || sym.Kind == parser.GlobalSymbol) {
+			continue
+		}
+		unused = append(unused, util.PositionError{
+			Position: sym.Position,
+			Code:     diagnostics.CodeUnused,
+			Msg:      fmt.Sprintf("%s %s is never used", sym.Kind, sym.Name),
+		})
+	}
+	for _, imp := range c.imports {
+		if _, ok := c.usedImports[imp.Path]; !ok {
+			unused = append(unused, util.PositionError{
+				Position: imp.Position(),
+				Code:     diagnostics.CodeUnusedImport,
+				Msg:      fmt.Sprintf("import %q is never used", imp.Path),
+			})
+		}
+	}
+	sort.SliceStable(unused, func(i, j int) bool {
+		pi, pj := unused[i].Position, unused[j].Position
+		return pi.StartLine < pj.StartLine || (pi.StartLine == pj.StartLine && pi.StartCol < pj.StartCol)
+	})
+	c.Warnings = append(c.Warnings, unused...)
+}
+
+func (c *Checker) lookup(name string) (*parser.Symbol, bool) {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if sym, ok := c.scopes[i][name]; ok {
+			return sym, true
+		}
+	}
+	sym, ok := c.globals[name]
+	return sym, ok
+}
+
+func (c *Checker) checkBlock(block *parser.BlockStatement) {
+	c.pushScope()
+	c.checkStatements(block.Statements)
+	c.popScope()
+}
+
+// checkStatements checks a statement list in the current scope and reports whether control can reach its end. the
+// first statement after one that never falls through is warned about, the rest of the dead run is still checked
+func (c *Checker) checkStatements(stmts []parser.Statement) bool {
+	reachable, warned := true, false
+	for _, s := range stmts {
+		if !reachable && !warned {
+			c.appendWarning(s.Position(), diagnostics.CodeUnreachable, "unreachable code")
+			warned = true
+		}
+		c.Check(s)
+		if reachable {
+			reachable = fallsThrough(s)
+		}
+	}
+	return reachable
+}
+
+// fallsThrough reports whether control can continue past s. return, break and continue never do, an if/else only
+// when one of its branches can, and a while true loop only when something in it breaks out
+func fallsThrough(s parser.Statement) bool {
+	switch s := s.(type) {
+	case *parser.ReturnStatement, *parser.BreakStatement, *parser.ContinueStatement:
+		return false
+	case *parser.IfStatement:
+		if s.Fail == nil {
+			return true
+		}
+		return blockFallsThrough(s.Success) || blockFallsThrough(s.Fail)
+	case *parser.WhileStatement:
+		if cond, ok := s.Condition.(*parser.BooleanExpression); ok && cond.Value {
+			return breaksOut(s.Body.Statements)
+		}
+		return true
+	default:
+		return true
+	}
+}
+
+func blockFallsThrough(block *parser.BlockStatement) bool {
+	for _, s := range block.Statements {
+		if !fallsThrough(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// breaksOut reports whether a break in stmts leaves the loop they're the body of, breaks in nested loops don't
+func breaksOut(stmts []parser.Statement) bool {
+	for _, s := range stmts {
+		switch s := s.(type) {
+		case *parser.BreakStatement:
+			return true
+		case *parser.IfStatement:
+			if breaksOut(s.Success.Statements) || (s.Fail != nil && breaksOut(s.Fail.Statements)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *Checker) checkCondition(cond parser.Expression, stmt string) {
+	vt, ok := c.checkOperand(cond)
+	if ok && !vt.Equals(boolType) {
+		c.appendError(cond.Position(), diagnostics.CodeTypeMismatch, "%s condition must be Bool, got %s", stmt, vt)
+	}
+}
+
+func (c *Checker) checkReturn(node *parser.ReturnStatement) {
+	if node.Expr == nil {
+		if !c.currFncType.Equals(voidType) {
+			c.appendError(node.Position(), diagnostics.CodeMissingReturn, "missing return value in function returning %s", c.currFncType)
+		}
+		return
+	}
+
+	tuple, isTuple := node.Expr.(*parser.TupleExpression)
+	if isTuple && len(tuple.Items) != len(c.currFncType.Tuple) {
+		c.appendError(node.Position(), diagnostics.CodeNotDestructured, "returning %d values from function returning %s", len(tuple.Items), c.currFncType)
+		c.checkExpr(tuple)
+		return
+	}
+	if isTuple {
+		for i, item := range tuple.Items {
+			c.checkExprAs(item, c.currFncType.Tuple[i], "return")
+		}
+		c.Info.Types[tuple] = c.currFncType
+		return
+	}
+	c.checkExprAs(node.Expr, c.currFncType, "return")
+}
+
+func (c *Checker) checkImport(node *parser.ImportStatement) {
+	if strings.HasSuffix(node.Path, ".gl3") {
+		f, err := os.ReadFile(node.Path)
+		if err != nil {
+			c.appendError(node.Position(), diagnostics.CodeImportNotFound, "cannot find %s file described in import stmt", node.Path)
+			return
+		}
+		c.imports = append(c.imports, node)
+		declares, globalDeclares, structs := emitter.FindDeclares(string(f))
+		for _, st := range structs {
+			c.structFieldIndexes[st.Name] = st.Names
+			c.structFields[st.Name] = st.Types
+		}
+		for _, d := range declares {
+			sym := &parser.Symbol{
+				Kind:     parser.FunctionSymbol,
+				Name:     d.Name,
+				Type:     d.ReturnType,
+				Params:   d.ParamTypes,
+				Variadic: d.Variadic,
+			}
+			c.funcs[d.Name] = sym
+			c.importOf[sym] = node.Path
+		}
+		for _, d := range globalDeclares {
+			sym := c.declare(parser.GlobalSymbol, d.Name, d.Type, nil)
+			c.importOf[sym] = node.Path
+			if d.Constant {
+				c.constVars[d.Name] = struct{}{}
+			}
+		}
+		return
+	}
+
+	c.importsFound[node.Path] = struct{}{}
+	if node.Path == "asm" {
+		c.imports = append(c.imports, node)
+		return
+	}
+	builtins, ok := emitter.GetBuiltinModule(node.Path)
+	if !ok {
+		c.appendError(node.Position(), diagnostics.CodeImportNotFound, "couldn't import builtin module %s", node.Path)
+		return
+	}
+	c.imports = append(c.imports, node)
+	for name, def := range builtins {
+		sym := &parser.Symbol{
+			Kind:     parser.FunctionSymbol,
+			Name:     name,
+			Type:     def.RetGlType,
+			Params:   def.GlParams,
+			Variadic: def.Variadic,
+		}
+		c.funcs[name] = sym
+		c.importOf[sym] = node.Path
+	}
+}
+
+// assignable reports whether a value of type from can be stored where a value of type to is expected
+func assignable(to, from lexer.VarType) bool {
+	// char and int8 are both i8 once lowered, see the emitter's infix handling
+	if (to.Base == lexer.Char && from.Base == lexer.Int8) || (to.Base == lexer.Int8 && from.Base == lexer.Char) {
+		from.Base = to.Base
+	}
+	return to.Equals(from)
+}
+
+// sameIntType is type equality, apart from the emitter allowing char op int8
+func sameIntType(left, right lexer.VarType) bool {
+	return left.Equals(right) || (left.Base == lexer.Char && right.Base == lexer.Int8)
+}
+
+// checkExprAs checks expr is usable where a value of type want is expected, giving null, some, ok and err the type
+// they need to be built as
+func (c *Checker) checkExprAs(expr parser.Expression, want lexer.VarType, context string) (lexer.VarType, bool) {
+	switch e := expr.(type) {
+	case *parser.NullLiteral:
+		if !want.Optional {
+			c.appendError(e.Position(), diagnostics.CodeBadWrap, "null used where non optional type %s is expected", want)
+			return lexer.VarType{}, false
+		}
+		c.Info.Types[e] = want
+		return want, true
+	case *parser.WrapExpression:
+		vt, ok := c.checkWrap(e, want)
+		if ok {
+			c.Info.Types[e] = vt
+		}
+		return vt, ok
+	}
+
+	vt, ok := c.checkExpr(expr)
+	if !ok {
+		return vt, false
+	}
+	if assignable(want, vt) {
+		return vt, true
+	}
+
+	if vt.IsWrapped() && assignable(want, vt.Unwrapped()) {
+		c.appendError(expr.Position(), diagnostics.CodeNotUnwrapped, "value of type %s must be unwrapped with try, ? or orelse before use", vt)
+	} else if vt.Tuple != nil && want.Tuple == nil {
+		c.appendError(expr.Position(), diagnostics.CodeNotDestructured, "multiple values of type %s must be destructured, i.e def int a, int b = f()", vt)
+	} else {
+		c.appendError(expr.Position(), diagnostics.CodeTypeMismatch, "cannot use value of type %s as %s in %s", vt, want, context)
+	}
+	return vt, false
+}
+
+// checkWrap checks some(x), ok(x) and err(x) against the optional or result type they're building
+func (c *Checker) checkWrap(node *parser.WrapExpression, want lexer.VarType) (lexer.VarType, bool) {
+	switch node.Token.Literal {
+	case "some":
+		if !want.Optional {
+			c.appendError(node.Position(), diagnostics.CodeBadWrap, "some used where non optional type %s is expected", want)
+			c.checkExpr(node.Value)
+			return lexer.VarType{}, false
+		}
+	case "ok", "err":
+		if want.ErrType == nil {
+			c.appendError(node.Position(), diagnostics.CodeBadWrap, "%s used where non result type %s is expected", node.Token.Literal, want)
+			c.checkExpr(node.Value)
+			return lexer.VarType{}, false
+		}
+	}
+
+	if node.Token.Literal == "err" {
+		c.checkExprAs(node.Value, *want.ErrType, "err")
+	} else {
+		c.checkExprAs(node.Value, want.Unwrapped(), node.Token.Literal)
+	}
+	return want, true
+}
+
+// checkOperand checks an expression whose value is used directly, which optionals, results and multiple values can't be
+func (c *Checker) checkOperand(expr parser.Expression) (lexer.VarType, bool) {
+	vt, ok := c.checkExpr(expr)
+	if !ok {
+		return vt, false
+	}
+	if vt.IsWrapped() {
+		c.appendError(expr.Position(), diagnostics.CodeNotUnwrapped, "value of type %s must be unwrapped with try, ? or orelse before use", vt)
+		return vt, false
+	}
+	if vt.Tuple != nil {
+		c.appendError(expr.Position(), diagnostics.CodeNotDestructured, "multiple values of type %s must be destructured before use", vt)
+		return vt, false
+	}
+	return vt, true
+}
+
+// checkExpr infers the type of expr, reporting anything the emitter wouldn't be able to lower and recording the type
+// for it. ok is false when there's no type to carry on checking with, the reason having been reported already
+func (c *Checker) checkExpr(expr parser.Expression) (lexer.VarType, bool) {
+	vt, ok := c.inferExpr(expr)
+	if ok {
+		c.Info.Types[expr] = vt
+	}
+	return vt, ok
+}
+
+func (c *Checker) inferExpr(expr parser.Expression) (lexer.VarType, bool) {
+	switch e := expr.(type) {
+	case *parser.IntegerLiteral:
+		return e.Type, true
+	case *parser.FloatLiteral:
+		return e.Type, true
+	case *parser.BooleanExpression:
+		return boolType, true
+	case *parser.StringLiteral:
+		return lexer.VarType{Base: lexer.Char, Pointer: 1}, true
+	case *parser.SizeofExpression:
+		return lexer.VarType{Base: lexer.Uint}, c.checkStructsDefined(e.Type, e.Position())
+	case *parser.IdentifierExpression:
+		sym, ok := c.lookup(e.Value)
+		if !ok {
+			c.appendError(e.Position(), diagnostics.CodeUndefinedVariable, "undefined variable %s", e.Value)
+			return lexer.VarType{}, false
+		}
+		c.Info.Symbols[e] = sym
+		c.use(sym)
+		return sym.Type, true
+	case *parser.NullLiteral:
+		c.appendError(e.Position(), diagnostics.CodeBadWrap, "cannot infer the optional type of null here, use it as a def, assignment, return value or call argument")
+	case *parser.WrapExpression:
+		if e.Token.Literal != "some" {
+			c.appendError(e.Position(), diagnostics.CodeBadWrap, "cannot infer the result type of %s here, use it as a def, assignment, return value or call argument", e.Token.Literal)
+			c.checkExpr(e.Value)
+			return lexer.VarType{}, false
+		}
+		// some(x) on its own is just ?T of whatever x is
+		vt, ok := c.checkOperand(e.Value)
+		vt.Optional = true
+		return vt, ok
+	case *parser.TryExpression:
+		return c.checkTry(e)
+	case *parser.TupleExpression:
+		vt := lexer.VarType{}
+		allOk := true
+		for _, item := range e.Items {
+			itemVt, ok := c.checkOperand(item)
+			allOk = allOk && ok
+			vt.Tuple = append(vt.Tuple, itemVt)
+		}
+		return vt, allOk
 	case *parser.PrefixExpression:
-		c.Check(node.Right)
+		return c.checkPrefix(e)
+	case *parser.InfixExpression:
+		return c.checkInfix(e)
 	case *parser.AssignmentExpression:
-		name := c.getIdentNameAssign(node.Left, true)
-		if _, ok := c.constVars[name]; ok {
-			c.appendError(node.Position(), "cannot assign to constant variable '%s'\n", name)
+		return c.checkAssignment(e)
+	case *parser.ReferenceExpression:
+		if e.Var == nil {
+			c.appendError(&e.Token.Position, diagnostics.CodeBadAddress, "can only take the address of a variable")
+			return lexer.VarType{}, false
 		}
-		c.Check(node.Left)
-		c.Check(node.Right)
-	case *parser.StructInitializationExpression:
-		for _, e := range node.Values {
-			c.Check(e)
+		vt, ok := c.checkExpr(e.Var)
+		if !ok {
+			return vt, false
 		}
-	case *parser.ArrayLiteral:
-		for _, e := range node.Items {
-			c.Check(e)
+		if sym := c.Info.Symbols[e.Var]; sym.Kind != parser.VariableSymbol {
+			c.appendError(e.Position(), diagnostics.CodeBadAddress, "cannot take the address of %s, only local variables can be referenced", e.Var.Value)
+			return vt, false
+		}
+		vt.Pointer++
+		return vt, true
+	case *parser.DereferenceExpression:
+		vt, ok := c.checkOperand(e.Var)
+		if !ok {
+			return vt, false
+		}
+		if vt.Pointer == 0 {
+			c.appendError(e.Position(), diagnostics.CodeBadAddress, "cannot deref non-ptr type %s", vt)
+			return vt, false
 		}
+		vt.Pointer--
+		return vt, true
+	case *parser.CastExpression:
+		return c.checkCast(e)
 	case *parser.CallExpression:
-		for _, arg := range node.Params {
-			c.Check(arg)
+		return c.checkCall(e)
+	case *parser.ArrayLiteral:
+		if _, ok := c.importsFound["arrays"]; !ok {
+			c.appendError(e.Position(), diagnostics.CodeModuleNotImported, "array literal used without stdlib module 'arrays' imported")
+		}
+		c.usedImports["arrays"] = struct{}{}
+		if !c.checkStructsDefined(e.Type, e.Position()) {
+			return lexer.VarType{}, false
+		}
+		for _, item := range e.Items {
+			c.checkExprAs(item, e.Type, "array literal")
+		}
+		vt := e.Type
+		vt.Pointer++
+		return vt, true
+	case *parser.StructInitializationExpression:
+		fields, ok := c.structFields[e.Name]
+		if !ok {
+			c.appendError(e.Position(), diagnostics.CodeUndefinedStruct, "undefined struct %s", e.Name)
+			return lexer.VarType{}, false
+		}
+		if len(e.Values) != len(fields) {
+			c.appendError(e.Position(), diagnostics.CodeArgumentCount, "struct %s has %d fields, %d given", e.Name, len(fields), len(e.Values))
+		}
+		for i, v := range e.Values {
+			if i < len(fields) {
+				c.checkExprAs(v, fields[i], "initialization of "+e.Name)
+			} else {
+				c.checkExpr(v)
+			}
+		}
+		return lexer.VarType{IsStructType: true, StructName: e.Name}, true
+	}
+	return lexer.VarType{}, false
+}
+
+func (c *Checker) checkPrefix(node *parser.PrefixExpression) (lexer.VarType, bool) {
+	vt, ok := c.checkOperand(node.Right)
+	if !ok {
+		return vt, false
+	}
+	_, signed := signedBases[vt.Base]
+	switch {
+	case node.Operator == "!" && vt.Equals(boolType):
+	case node.Operator == "-" && ((signed && vt.Pointer == 0 && !vt.IsStructType) || vt.Equals(floatType)):
+	default:
+		c.appendError(node.Position(), diagnostics.CodeBadOperator, "operator %s invalid for type %s", node.Operator, vt)
+		return vt, false
+	}
+	return vt, true
+}
+
+func (c *Checker) checkInfix(node *parser.InfixExpression) (lexer.VarType, bool) {
+	switch node.Operator {
+	case ".":
+		return c.checkFieldAccess(node)
+	case "orelse":
+		vt, ok := c.checkExpr(node.Left)
+		if ok && !vt.IsWrapped() {
+			c.appendError(node.Position(), diagnostics.CodeBadUnwrap, "orelse used on non optional, non result type %s", vt)
+			ok = false
 		}
+		if !ok {
+			c.checkExpr(node.Right)
+			return vt, false
+		}
+		c.checkExprAs(node.Right, vt.Unwrapped(), "orelse")
+		return vt.Unwrapped(), true
+	}
+
+	leftVt, leftOk := c.checkOperand(node.Left)
+	rightVt, rightOk := c.checkOperand(node.Right)
+	if !leftOk || !rightOk {
+		return lexer.VarType{}, false
+	}
+
+	_, leftInt := intBases[leftVt.Base]
+	_, rightInt := intBases[rightVt.Base]
+	leftInt = leftInt && leftVt.Pointer == 0 && !leftVt.IsStructType
+	rightInt = rightInt && rightVt.Pointer == 0 && !rightVt.IsStructType
+	// chars only get the operators that don't depend on signedness
+	leftIntOrChar := leftInt || (leftVt.Base == lexer.Char && leftVt.Pointer == 0)
+	rightIntOrChar := rightInt || (rightVt.Base == lexer.Char && rightVt.Pointer == 0)
+	floats := leftVt.Equals(floatType) && rightVt.Equals(floatType)
+
+	switch node.Operator {
+	case "+", "-", "*":
+		if node.Operator != "*" && leftVt.Pointer > 0 && !leftVt.IsWrapped() && rightInt {
+			return leftVt, true
+		}
+		if (leftIntOrChar && rightIntOrChar && sameIntType(leftVt, rightVt)) || floats {
+			return leftVt, true
+		}
+	case "/":
+		if (leftInt && rightInt && leftVt.Equals(rightVt)) || floats {
+			return leftVt, true
+		}
+	case "==", "!=":
+		if (leftIntOrChar && rightIntOrChar && sameIntType(leftVt, rightVt)) || floats {
+			return boolType, true
+		}
+	case "<", ">", "<=", ">=":
+		if (leftInt && rightInt && leftVt.Equals(rightVt)) || floats {
+			return boolType, true
+		}
+	case "&&", "||":
+		if leftVt.Equals(boolType) && rightVt.Equals(boolType) {
+			return boolType, true
+		}
+	}
+
+	c.appendError(node.Position(), diagnostics.CodeBadOperator, "operator %s invalid for types %s and %s", node.Operator, leftVt, rightVt)
+	return lexer.VarType{}, false
+}
+
+func (c *Checker) checkFieldAccess(node *parser.InfixExpression) (lexer.VarType, bool) {
+	leftVt, ok := c.checkOperand(node.Left)
+	if !ok {
+		return leftVt, false
+	}
+	if !leftVt.IsStructType || leftVt.Pointer > 1 {
+		c.appendError(node.Position(), diagnostics.CodeUnknownField, "non struct type %s on lhs of dot operator", leftVt)
+		return lexer.VarType{}, false
+	}
+	ident, ok := node.Right.(*parser.IdentifierExpression)
+	if !ok {
+		c.appendError(node.Position(), diagnostics.CodeUnknownField, "non identifier %s on rhs of dot operator", node.Right)
+		return lexer.VarType{}, false
+	}
+	fieldIndexes, ok := c.structFieldIndexes[leftVt.StructName]
+	if !ok {
+		c.appendError(node.Position(), diagnostics.CodeUndefinedStruct, "undefined struct %s", leftVt.StructName)
+		return lexer.VarType{}, false
+	}
+	idx, ok := fieldIndexes[ident.Value]
+	if !ok {
+		c.appendError(node.Position(), diagnostics.CodeUnknownField, "struct %s has no field %s", leftVt.StructName, ident.Value)
+		return lexer.VarType{}, false
+	}
+	sym := &parser.Symbol{
+		Kind:   parser.FieldSymbol,
+		Name:   ident.Value,
+		Type:   c.structFields[leftVt.StructName][idx],
+		Struct: leftVt.StructName,
+		Index:  idx,
+	}
+	c.Info.Symbols[node] = sym
+	return sym.Type, true
+}
+
+func (c *Checker) checkAssignment(node *parser.AssignmentExpression) (lexer.VarType, bool) {
+	name := c.getIdentNameAssign(node.Left, true)
+	if _, ok := c.constVars[name]; ok {
+		c.appendError(node.Position(), diagnostics.CodeAssignToConst, "cannot assign to constant variable '%s'", name)
+	}
+
+	if infix, ok := node.Left.(*parser.InfixExpression); ok {
+		if _, ok := infix.Left.(*parser.IdentifierExpression); !ok {
+			c.appendError(node.Position(), diagnostics.CodeBadAssignTarget, "expected identifier on lhs of dot operator")
+		}
+	}
+
+	leftVt, ok := c.checkExpr(node.Left)
+	if !ok {
+		c.checkExpr(node.Right)
+		return leftVt, false
+	}
+	if sym := c.Info.Symbols[node.Left]; sym != nil && sym.Kind == parser.ParameterSymbol {
+		c.appendError(node.Position(), diagnostics.CodeAssignToConst, "cannot assign to parameter %s, copy it into a variable first", sym.Name)
+	}
+	return c.checkExprAs(node.Right, leftVt, "assignment")
+}
+
+func (c *Checker) checkTry(node *parser.TryExpression) (lexer.VarType, bool) {
+	vt, ok := c.checkExpr(node.Expr)
+	if !ok {
+		return vt, false
+	}
+	if !vt.IsWrapped() {
+		c.appendError(node.Position(), diagnostics.CodeBadUnwrap, "try used on non optional, non result type %s", vt)
+		return vt, false
+	}
+
+	retVt := c.currFncType
+	if !retVt.IsWrapped() {
+		c.appendError(node.Position(), diagnostics.CodeBadUnwrap, "try can only be used in functions returning an optional or result type, enclosing function returns %s", retVt)
+	} else if vt.ErrType != nil && retVt.ErrType != nil && !vt.ErrType.Equals(*retVt.ErrType) {
+		c.appendError(node.Position(), diagnostics.CodeBadUnwrap, "try on result with error type %s in function returning error type %s", vt.ErrType, retVt.ErrType)
+	} else if !retVt.Optional && vt.ErrType == nil {
+		c.appendError(node.Position(), diagnostics.CodeBadUnwrap, "try on optional %s in function returning result %s, there's no error to return", vt, retVt)
+	}
+	return vt.Unwrapped(), true
+}
+
+func (c *Checker) checkCast(node *parser.CastExpression) (lexer.VarType, bool) {
+	dst := node.Type
+	c.checkStructsDefined(dst, node.Position())
+	src, ok := c.checkOperand(node.Expr)
+	if !ok {
+		return dst, true
+	}
+	if (src.IsStructType && src.Pointer == 0) || (dst.IsStructType && dst.Pointer == 0) {
+		c.appendError(node.Position(), diagnostics.CodeBadCast, "casts using struct types are disallowed")
+		return dst, true
+	}
+
+	// the source is looked at as an llvm type in the emitter, so chars are ints there but not as a destination
+	_, srcInt := castIntBases[src.Base]
+	srcInt = (srcInt || src.Base == lexer.Char) && src.Pointer == 0
+	_, dstInt := castIntBases[dst.Base]
+	srcFloat := src.Equals(floatType)
+	dstFloat := dst.Equals(floatType)
+
+	switch {
+	case srcInt && dstInt && dst.Pointer == 0:
+	case srcInt && dst.Pointer > 0:
+	case src.Pointer > 0 && dstInt && dst.Pointer == 0:
+		if dst.Base != lexer.Int && dst.Base != lexer.Uint {
+			c.appendWarning(node.Position(), diagnostics.CodePointerTruncation, "pointer to %s cast may truncate", dst)
+		}
+	case srcInt && dstFloat:
+	case srcFloat && dstInt && dst.Pointer == 0:
+	case src.Pointer > 0 && dst.Pointer > 0:
+	default:
+		c.appendError(node.Position(), diagnostics.CodeBadCast, "cannot cast %s to %s", src, dst)
+	}
+	return dst, true
+}
+
+func (c *Checker) checkCall(node *parser.CallExpression) (lexer.VarType, bool) {
+	name := node.Function.Value
+	if name == "va_start" || name == "va_arg" || name == "va_end" {
+		return c.checkVaCall(node)
+	}
+	if _, ok := c.importsFound["asm"]; ok && name == "__asm__salloc" {
+		c.usedImports["asm"] = struct{}{}
+		return c.checkAsmSalloc(node)
+	}
 
-		if _, ok := c.importsFound["io"]; ok && (node.Function.Value == "print" || node.Function.Value == "println") {
-			c.checkPrintArgs(node)
+	sym, ok := c.funcs[name]
+	if !ok {
+		for _, arg := range node.Params {
+			c.checkExpr(arg)
 		}
+		for moduleName, module := range c.builtinNames {
+			if _, ok := module[name]; ok {
+				c.appendError(node.Position(), diagnostics.CodeModuleNotImported, "stdlib function '%s' used without stdlib module '%s' imported", name, moduleName)
+				return lexer.VarType{}, false
+			}
+		}
+		c.appendError(node.Position(), diagnostics.CodeUndefinedFunction, "undefined function %s", name)
+		return lexer.VarType{}, false
+	}
 
-		var moduleName string
-		moduleFound := false
-		for name, module := range c.builtinNames {
-			if _, ok := module[node.Function.Value]; ok {
-				moduleName = name
-				moduleFound = true
-				break
+	c.Info.Symbols[node] = sym
+	c.use(sym)
+	if len(node.Params) < len(sym.Params) || (!sym.Variadic && len(node.Params) > len(sym.Params)) {
+		c.appendError(node.Position(), diagnostics.CodeArgumentCount, "function %s takes %d arguments, %d given", name, len(sym.Params), len(node.Params))
+	}
+	argTypes := make([]lexer.VarType, len(node.Params))
+	argOk := make([]bool, len(node.Params))
+	for i, arg := range node.Params {
+		switch {
+		case i < len(sym.Params) && sym.Params[i].Equals(emitter.AnyPointer):
+			argTypes[i], argOk[i] = c.checkOperand(arg)
+			if argOk[i] && argTypes[i].Pointer == 0 {
+				c.appendError(arg.Position(), diagnostics.CodeTypeMismatch, "cannot use value of type %s as a pointer in argument to %s", argTypes[i], name)
+				argOk[i] = false
 			}
+		case i < len(sym.Params):
+			argTypes[i], argOk[i] = c.checkExprAs(arg, sym.Params[i], "argument to "+name)
+		default:
+			argTypes[i], argOk[i] = c.checkOperand(arg)
 		}
-		if _, ok := c.importsFound[moduleName]; (ok && moduleFound) || !moduleFound {
-			return
+	}
+
+	if _, ok := c.importsFound["io"]; ok && (name == "print" || name == "println") {
+		c.checkPrintArgs(node, argTypes, argOk)
+	}
+
+	return sym.Type, true
+}
+
+func (c *Checker) checkVaCall(node *parser.CallExpression) (lexer.VarType, bool) {
+	name := node.Function.Value
+	vaListVt := lexer.VarType{Base: lexer.VaList}
+	if !c.currFncVariadic {
+		c.appendError(node.Position(), diagnostics.CodeBadIntrinsic, "%s can only be used in variadic functions", name)
+	}
+
+	switch name {
+	case "va_start":
+		if len(node.Params) != 0 {
+			c.appendError(node.Position(), diagnostics.CodeArgumentCount, "va_start takes no arguments, %d given", len(node.Params))
 		}
-		c.appendError(node.Position(), "stdlib function '%s' used without stdlib module '%s' imported, did you mean to do this?\n", node.Function.Value, moduleName)
-	case *parser.StructStatement:
-		c.structFieldTypes[node.Name] = make(map[string]lexer.VarType)
-		for name, idx := range node.Names {
-			c.structFieldTypes[node.Name][name] = node.Types[idx]
+		return vaListVt, true
+	case "va_arg":
+		if len(node.Params) != 2 {
+			c.appendError(node.Position(), diagnostics.CodeArgumentCount, "va_arg takes a va_list and a sizeof expr, %d arguments given", len(node.Params))
+			return lexer.VarType{}, false
 		}
+		c.checkExprAs(node.Params[0], vaListVt, "argument to va_arg")
+		sizeof, ok := node.Params[1].(*parser.SizeofExpression)
+		if !ok {
+			c.appendError(node.Params[1].Position(), diagnostics.CodeBadIntrinsic, "second argument of va_arg must be a sizeof expr giving the type to read")
+			return lexer.VarType{}, false
+		}
+		return sizeof.Type, true
+	default:
+		if len(node.Params) != 1 {
+			c.appendError(node.Position(), diagnostics.CodeArgumentCount, "va_end takes a va_list, %d arguments given", len(node.Params))
+		} else {
+			c.checkExprAs(node.Params[0], vaListVt, "argument to va_end")
+		}
+		return voidType, true
 	}
 }
 
-func (c *Checker) appendError(pos *util.Position, msg string, args ...any) {
-	c.Errors = append(c.Errors, util.PositionError{
-		Position: pos,
-		Msg:      fmt.Sprintf(msg, args...),
-	})
+func (c *Checker) checkAsmSalloc(node *parser.CallExpression) (lexer.VarType, bool) {
+	if len(node.Params) != 2 {
+		c.appendError(node.Position(), diagnostics.CodeArgumentCount, "invalid amount of arguments for __asm__salloc: %d", len(node.Params))
+		return lexer.VarType{}, false
+	}
+	if _, ok := node.Params[0].(*parser.IntegerLiteral); !ok {
+		c.appendError(node.Position(), diagnostics.CodeBadIntrinsic, "first argument of __asm__salloc is not integer: %s", node.Params[0])
+	}
+	sizeof, ok := node.Params[1].(*parser.SizeofExpression)
+	if !ok {
+		c.appendError(node.Position(), diagnostics.CodeBadIntrinsic, "second argument of __asm__salloc is not sizeof expr: %s", node.Params[1])
+		return lexer.VarType{}, false
+	}
+	vt := sizeof.Type
+	vt.Pointer++
+	return vt, true
 }
 
 func (c *Checker) getIdentNameAssign(expr parser.Expression, error bool) string {
@@ -146,75 +1047,159 @@ func (c *Checker) getIdentNameAssign(expr parser.Expression, error bool) string
 		}
 	default:
 		if error {
-			c.appendError(expr.Position(), "unknown node %T on lhs of assignment", expr)
+			c.appendError(expr.Position(), diagnostics.CodeBadAssignTarget, "unknown node %T on lhs of assignment", expr)
 			return ""
 		}
 	}
 	return ""
 }
 
-func (c *Checker) getVarType(expr parser.Expression) (lexer.VarType, bool) {
-	switch e := expr.(type) {
-	case *parser.IdentifierExpression:
-		vt, ok := c.varTypes[e.Value]
-		return vt, ok
-	case *parser.DereferenceExpression:
-		if vi, ok := e.Var.(*parser.IdentifierExpression); ok {
-			vt, ok := c.varTypes[vi.Value]
-			return vt, ok
-		} else {
-			return c.getVarType(e.Var)
-		}
-	case *parser.InfixExpression:
-		if e.Operator == "." {
-			// NOTE: we assume this is correct, i haven't implemented checking at the time of writing but ehh, the X:{4i32}.a usecase is kinda dogshit and i'm not sure that's something i'd like to support.. it's not like structs have constructors or anything to make this something you'd want to do ..
-			vt, ok := c.varTypes[e.Left.(*parser.IdentifierExpression).Value]
-			if !ok || !vt.IsStructType {
-				return lexer.VarType{}, false
-			}
-			fieldTypes, ok := c.structFieldTypes[vt.StructName]
-			if !ok {
-				return lexer.VarType{}, false
-			}
-			ft, ok := fieldTypes[e.Right.(*parser.IdentifierExpression).Value]
-			return ft, ok
-		}
-	case *parser.BooleanExpression:
-		return lexer.VarType{Base: lexer.Bool}, true
-	case *parser.IntegerLiteral:
-		return e.Type, true
-	case *parser.StringLiteral:
-		return lexer.VarType{Base: lexer.Char, Pointer: 1}, true
-	case *parser.FloatLiteral:
-		return e.Type, true
+func (c *Checker) checkPrintArgs(node *parser.CallExpression, argTypes []lexer.VarType, argOk []bool) {
+	if len(node.Params) == 0 {
+		return
 	}
-	return lexer.VarType{}, false
-}
-
-func (c *Checker) checkPrintArgs(node *parser.CallExpression) {
 	var fmtStr string
 	if s, ok := node.Params[0].(*parser.StringLiteral); ok {
 		fmtStr = s.Value
 	} else {
-		c.appendError(node.Position(), "first argument of print/ln function should be string literal")
+		c.appendError(node.Position(), diagnostics.CodeBadFormat, "first argument of print/ln function should be string literal")
+		return
+	}
+	// string literals carry their nul terminator
+	specifiers, err := parsePrintFormat(strings.TrimSuffix(fmtStr, "\x00"))
+	if err != nil {
+		c.appendError(node.Params[0].Position(), diagnostics.CodeBadFormat, "invalid print/ln format string: %s", err)
 		return
 	}
-	r, _ := regexp.Compile("%(?:(?:b|c|s)|(?:(?:f|u|fu|)(?:y|w|d|l)))")
-	found := r.FindAllString(fmtStr, -1)
-	if len(found) != len(node.Params)-1 {
-		c.appendError(node.Position(), "print/ln function should have as many specifiers as arguments given")
+	if len(specifiers) != len(node.Params)-1 {
+		c.appendError(node.Position(), diagnostics.CodeBadFormat, "print/ln format string has %d specifiers but %d arguments were given", len(specifiers), len(node.Params)-1)
 		return
 	}
 
-	for i := range len(found) {
-		specifier := found[i]
+	for i, spec := range specifiers {
 		arg := node.Params[i+1]
-		switch specifier {
-		case "%b":
-			typ, ok := c.getVarType(arg)
-			if !ok || typ.Base != lexer.Bool || typ.Pointer > 0 {
-				c.appendError(node.Position(), "printf arg %d with specifier %%b isnt't bool, has type: %s", i, typ.String())
+		typ := argTypes[i+1]
+		if !argOk[i+1] || spec.accepts(typ) {
+			continue
+		}
+		if suggested, ok := specifierFor(typ, spec.suffix); ok {
+			c.appendError(arg.Position(), diagnostics.CodeBadFormat, "print/ln specifier %s doesn't match argument of type %s, use %s", spec.text, typ, suggested)
+		} else {
+			c.appendError(arg.Position(), diagnostics.CodeBadFormat, "print/ln specifier %s doesn't match argument of type %s, which no specifier can print", spec.text, typ)
+		}
+	}
+}
+
+// printSpecifier is a format specifier as understood by vprint in builtins/io.c
+type printSpecifier struct {
+	text     string // as written, i.e %fud
+	kind     byte   // b, c, s or the integer width y, w, d or l
+	unsigned bool
+	suffix   bool
+}
+
+var printIntWidths = map[byte][2]lexer.BaseVarType{
+	'y': {lexer.Int8, lexer.Uint8},
+	'w': {lexer.Int16, lexer.Uint16},
+	'd': {lexer.Int32, lexer.Uint32},
+	'l': {lexer.Int, lexer.Uint},
+}
+
+// parsePrintFormat returns the specifiers in a print/ln format string that take an argument, in order
+func parsePrintFormat(format string) ([]printSpecifier, error) {
+	var specifiers []printSpecifier
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		start := i
+		i++
+		if i == len(format) {
+			return nil, fmt.Errorf("format string ends in a lone %%, use %%%% for a literal %%")
+		}
+		switch format[i] {
+		case '%':
+			continue
+		case 'b', 'c', 's':
+			specifiers = append(specifiers, printSpecifier{text: format[start : i+1], kind: format[i]})
+			continue
+		}
+
+		// integers are %[f][u]width, in that order
+		spec := printSpecifier{}
+		if format[i] == 'f' {
+			spec.suffix = true
+			i++
+		}
+		if i < len(format) && format[i] == 'u' {
+			spec.unsigned = true
+			i++
+		}
+		if i == len(format) {
+			return nil, fmt.Errorf("specifier %s is missing its width, one of y, w, d or l", format[start:])
+		}
+		if _, ok := printIntWidths[format[i]]; !ok {
+			return nil, fmt.Errorf("unknown specifier %q, expected %%b, %%c, %%s, %%%% or an integer specifier like %%d", format[start:i+1])
+		}
+		spec.kind = format[i]
+		spec.text = format[start : i+1]
+		specifiers = append(specifiers, spec)
+	}
+	return specifiers, nil
+}
+
+// accepts reports whether vprint reads a value of type vt for the specifier
+func (ps printSpecifier) accepts(vt lexer.VarType) bool {
+	if vt.IsStructType || vt.IsWrapped() || vt.Tuple != nil {
+		return false
+	}
+	byteBase := vt.Base == lexer.Char || vt.Base == lexer.Int8
+	switch ps.kind {
+	case 'b':
+		return vt.Equals(boolType)
+	case 'c':
+		return byteBase && vt.Pointer == 0
+	case 's':
+		return byteBase && vt.Pointer == 1
+	}
+	bases := printIntWidths[ps.kind]
+	if ps.unsigned {
+		return vt.Pointer == 0 && vt.Base == bases[1]
+	}
+	return vt.Pointer == 0 && vt.Base == bases[0]
+}
+
+// specifierFor suggests the specifier printing a value of type vt, keeping the f prefix if the original had one
+func specifierFor(vt lexer.VarType, suffix bool) (string, bool) {
+	if vt.IsStructType || vt.IsWrapped() || vt.Tuple != nil {
+		return "", false
+	}
+	switch {
+	case vt.Equals(boolType):
+		return "%b", true
+	case vt.Base == lexer.Char && vt.Pointer == 0:
+		return "%c", true
+	case (vt.Base == lexer.Char || vt.Base == lexer.Int8) && vt.Pointer == 1:
+		return "%s", true
+	case vt.Pointer > 0:
+		return "", false
+	}
+	for width, bases := range printIntWidths {
+		for i, base := range bases {
+			if base != vt.Base {
+				continue
+			}
+			var spec strings.Builder
+			spec.WriteByte('%')
+			if suffix {
+				spec.WriteByte('f')
+			}
+			if i == 1 {
+				spec.WriteByte('u')
 			}
+			spec.WriteByte(width)
+			return spec.String(), true
 		}
 	}
+	return "", false
 }
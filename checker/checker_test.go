@@ -0,0 +1,446 @@
+package checker
+
+import (
+	"grianlang3/diagnostics"
+	"grianlang3/lexer"
+	"grianlang3/parser"
+	"slices"
+	"testing"
+)
+
+type InputCodes struct {
+	input string
+	codes []string // code of each error then each warning reported, in order
+}
+
+func TestTypeRules(t *testing.T) {
+	tests := map[string]InputCodes{
+		"matching def": {
+			"fnc main() -> int32 {\n def int32 x = 1i32\n return x\n}",
+			nil,
+		},
+		"mismatched def": {
+			"fnc main() -> int32 {\n def int32 x = 1\n return x\n}",
+			[]string{diagnostics.CodeTypeMismatch},
+		},
+		"mismatched operands": {
+			"fnc main() -> int32 {\n def int x = 1\n return (x + 1i32) as int32\n}",
+			[]string{diagnostics.CodeBadOperator},
+		},
+		"float arithmetic": {
+			"fnc half(float f) -> float {\n return f / 2.0\n}\nfnc main() -> int32 {\n return half(1.0) as int32\n}",
+			nil,
+		},
+		"bool condition": {
+			"fnc main() -> int32 {\n if 1 == 1 {\n return 1i32\n }\n return 0i32\n}",
+			nil,
+		},
+		"int condition": {
+			"fnc main() -> int32 {\n if 1 {\n return 1i32\n }\n return 0i32\n}",
+			[]string{diagnostics.CodeTypeMismatch},
+		},
+		"matching argument": {
+			"fnc id(int32 x) -> int32 {\n return x\n}\nfnc main() -> int32 {\n return id(1i32)\n}",
+			nil,
+		},
+		"mismatched argument": {
+			"fnc id(int32 x) -> int32 {\n return x\n}\nfnc main() -> int32 {\n return id(1)\n}",
+			[]string{diagnostics.CodeTypeMismatch},
+		},
+		"argument count": {
+			"fnc id(int32 x) -> int32 {\n return x\n}\nfnc main() -> int32 {\n return id(1i32, 2i32)\n}",
+			[]string{diagnostics.CodeArgumentCount},
+		},
+		"undefined variable": {
+			"fnc main() -> int32 {\n return y\n}",
+			[]string{diagnostics.CodeUndefinedVariable},
+		},
+		"undefined function": {
+			"fnc main() -> int32 {\n return f()\n}",
+			[]string{diagnostics.CodeUndefinedFunction},
+		},
+		"unwrapped with orelse": {
+			"fnc main() -> int32 {\n def ?int32 x = some(1i32)\n return x orelse 0i32\n}",
+			nil,
+		},
+		"optional used without unwrapping": {
+			"fnc main() -> int32 {\n def ?int32 x = some(1i32)\n return x\n}",
+			[]string{diagnostics.CodeNotUnwrapped},
+		},
+		"null as non optional": {
+			"fnc main() -> int32 {\n def int32 x = null\n return x\n}",
+			[]string{diagnostics.CodeBadWrap},
+		},
+		"try in function returning optional": {
+			"fnc f(?int32 x) -> ?int32 {\n def int32 y = try x\n return some(y)\n}\nfnc main() -> int32 {\n return f(null) orelse 0i32\n}",
+			nil,
+		},
+		"try in function returning int": {
+			"fnc f(?int32 x) -> int32 {\n return try x\n}\nfnc main() -> int32 {\n return f(null)\n}",
+			[]string{diagnostics.CodeBadUnwrap},
+		},
+		"field access": {
+			"struct P {\n int32 x\n}\nfnc main() -> int32 {\n def P p = P:{ 1i32 }\n return p.x\n}",
+			nil,
+		},
+		"unknown field": {
+			"struct P {\n int32 x\n}\nfnc main() -> int32 {\n def P p = P:{ 1i32 }\n return p.y\n}",
+			[]string{diagnostics.CodeUnknownField},
+		},
+		"assign to const": {
+			"global const int32 x = 1i32\nfnc main() -> int32 {\n x = 2i32\n return x\n}",
+			[]string{diagnostics.CodeAssignToConst},
+		},
+		"destructured tuple": {
+			"fnc two() -> (int, int) {\n return 1, 2\n}\nfnc main() -> int32 {\n def int a, int b = two()\n return (a + b) as int32\n}",
+			nil,
+		},
+		"tuple not destructured": {
+			"fnc two() -> (int, int) {\n return 1, 2\n}\nfnc main() -> int32 {\n def int a = two()\n return a as int32\n}",
+			[]string{diagnostics.CodeNotDestructured},
+		},
+		"struct cast": {
+			"struct P {\n int32 x\n}\nfnc main() -> int32 {\n def P p = P:{ 1i32 }\n return p as int32\n}",
+			[]string{diagnostics.CodeBadCast},
+		},
+		"struct pointing to itself": {
+			"struct N {\n int32 v\n N* next\n ?N* prev\n}\nfnc main() -> int32 {\n def N n = N:{ 1i32, 0 as N*, null }\n return n.v\n}",
+			nil,
+		},
+		"undefined struct field": {
+			"struct A {\n B b\n}\nfnc main() -> int32 {\n return 0i32\n}",
+			[]string{diagnostics.CodeUndefinedStruct},
+		},
+		"struct field defined later": {
+			"struct A {\n B* b\n}\nstruct B {\n int x\n}\nfnc main() -> int32 {\n return 0i32\n}",
+			[]string{diagnostics.CodeUndefinedStruct},
+		},
+		"undefined struct param and return": {
+			"fnc f(Foo* _x) -> ?Bar {\n return null\n}\nfnc main() -> int32 {\n f(0 as Foo*)\n return 0i32\n}",
+			[]string{diagnostics.CodeUndefinedStruct, diagnostics.CodeUndefinedStruct, diagnostics.CodeUndefinedStruct},
+		},
+		"undefined struct def": {
+			"fnc main() -> int32 {\n def Foo* x = 0 as Foo*\n return 0i32\n}",
+			[]string{diagnostics.CodeUndefinedStruct, diagnostics.CodeUndefinedStruct, diagnostics.CodeUnused},
+		},
+		"undefined struct sizeof": {
+			"fnc main() -> int32 {\n return sizeof Foo as int32\n}",
+			[]string{diagnostics.CodeUndefinedStruct},
+		},
+		"break in loop": {
+			"fnc main() -> int32 {\n while true {\n break\n }\n return 0i32\n}",
+			nil,
+		},
+		"break outside loop": {
+			"fnc main() -> int32 {\n break\n return 0i32\n}",
+			[]string{diagnostics.CodeOutsideLoop, diagnostics.CodeUnreachable},
+		},
+		"address of a literal": {
+			"fnc main() -> int32 {\n return &5\n}",
+			[]string{diagnostics.CodeBadAddress},
+		},
+		"builtin argument": {
+			"import \"dbg\"\nfnc main() -> int32 {\n dbg_i64(1)\n return 0i32\n}",
+			nil,
+		},
+		"mismatched builtin argument": {
+			"import \"dbg\"\nfnc main() -> int32 {\n dbg_i64(true)\n return 0i32\n}",
+			[]string{diagnostics.CodeTypeMismatch},
+		},
+		"any pointer builtin argument": {
+			"import \"ralloc\"\nfnc main() -> int32 {\n def int32* p = malloc(4u64) as int32*\n free(p)\n return 0i32\n}",
+			nil,
+		},
+		"value as any pointer builtin argument": {
+			"import \"ralloc\"\nfnc main() -> int32 {\n free(3i32)\n return 0i32\n}",
+			[]string{diagnostics.CodeTypeMismatch},
+		},
+	}
+
+	runTests(t, tests)
+}
+
+func TestPrintFormat(t *testing.T) {
+	tests := map[string]InputCodes{
+		"matching specifiers": {
+			"import \"io\"\nfnc main() -> int32 {\n println(\"%d %l %b %s\", 1i32, 2, true, \"x\")\n return 0i32\n}",
+			nil,
+		},
+		"escaped percent": {
+			"import \"io\"\nfnc main() -> int32 {\n println(\"100%%\")\n return 0i32\n}",
+			nil,
+		},
+		"mismatched specifier": {
+			"import \"io\"\nfnc main() -> int32 {\n println(\"%d\", true)\n return 0i32\n}",
+			[]string{diagnostics.CodeBadFormat},
+		},
+		"too few arguments": {
+			"import \"io\"\nfnc main() -> int32 {\n println(\"%d %d\", 1i32)\n return 0i32\n}",
+			[]string{diagnostics.CodeBadFormat},
+		},
+		"non literal format": {
+			"import \"io\"\nfnc main() -> int32 {\n def char* f = \"%d\"\n println(f, 1i32)\n return 0i32\n}",
+			[]string{diagnostics.CodeBadFormat},
+		},
+		"float without width": {
+			"import \"io\"\nfnc main() -> int32 {\n println(\"%f\", 1.0)\n return 0i32\n}",
+			[]string{diagnostics.CodeBadFormat},
+		},
+		"every width and signedness": {
+			"import \"io\"\nfnc main() -> int32 {\n println(\"%y %w %uy %uw %ud %ul %c\", 1i8, 2i16, 3u8, 4u16, 5u32, 6u64, 'a')\n return 0i32\n}",
+			nil,
+		},
+		"typed specifiers": {
+			"import \"io\"\nfnc main() -> int32 {\n print(\"%fd %ful\", 1i32, 2u64)\n return 0i32\n}",
+			nil,
+		},
+		"wrong width": {
+			"import \"io\"\nfnc main() -> int32 {\n println(\"%d\", 1)\n return 0i32\n}",
+			[]string{diagnostics.CodeBadFormat},
+		},
+		"wrong signedness": {
+			"import \"io\"\nfnc main() -> int32 {\n println(\"%ud\", 1i32)\n return 0i32\n}",
+			[]string{diagnostics.CodeBadFormat},
+		},
+		"integer as string": {
+			"import \"io\"\nfnc main() -> int32 {\n println(\"%s\", 1i32)\n return 0i32\n}",
+			[]string{diagnostics.CodeBadFormat},
+		},
+		"unknown specifier": {
+			"import \"io\"\nfnc main() -> int32 {\n println(\"%q\", 1i32)\n return 0i32\n}",
+			[]string{diagnostics.CodeBadFormat},
+		},
+		"trailing percent": {
+			"import \"io\"\nfnc main() -> int32 {\n println(\"100%\")\n return 0i32\n}",
+			[]string{diagnostics.CodeBadFormat},
+		},
+		"too many arguments": {
+			"import \"io\"\nfnc main() -> int32 {\n println(\"%d\", 1i32, 2i32)\n return 0i32\n}",
+			[]string{diagnostics.CodeBadFormat},
+		},
+	}
+
+	runTests(t, tests)
+}
+
+func TestReturnAnalysis(t *testing.T) {
+	tests := map[string]InputCodes{
+		"return on every path": {
+			"fnc f(bool b) -> int32 {\n if b {\n return 1i32\n } else {\n return 2i32\n }\n}\nfnc main() -> int32 {\n return f(true)\n}",
+			nil,
+		},
+		"return missing on else path": {
+			"fnc f(bool b) -> int32 {\n if b {\n return 1i32\n }\n}\nfnc main() -> int32 {\n return f(true)\n}",
+			[]string{diagnostics.CodeMissingReturn},
+		},
+		"infinite loop needs no return": {
+			"fnc main() -> int32 {\n while true {\n }\n}",
+			nil,
+		},
+		"loop with break needs a return": {
+			"fnc main() -> int32 {\n while true {\n break\n }\n}",
+			[]string{diagnostics.CodeMissingReturn},
+		},
+		"void function without return": {
+			"fnc f() -> none {\n}\nfnc main() -> int32 {\n f()\n return 0i32\n}",
+			nil,
+		},
+		"return without value": {
+			"fnc main() -> int32 {\n return\n}",
+			[]string{diagnostics.CodeMissingReturn},
+		},
+		"wrong number of values": {
+			"fnc two() -> (int, int) {\n return 1, 2, 3\n}\nfnc main() -> int32 {\n def int a, int b = two()\n return (a + b) as int32\n}",
+			[]string{diagnostics.CodeNotDestructured},
+		},
+		"return in every branch of a nested if": {
+			"fnc sign(int x) -> int32 {\n if x < 0 {\n return -1i32\n } else {\n if x > 0 {\n return 1i32\n } else {\n return 0i32\n }\n }\n}\nfnc main() -> int32 {\n return sign(1)\n}",
+			nil,
+		},
+		"nested if without else": {
+			"fnc sign(int x) -> int32 {\n if x < 0 {\n return -1i32\n } else {\n if x > 0 {\n return 1i32\n }\n }\n}\nfnc main() -> int32 {\n return sign(1)\n}",
+			[]string{diagnostics.CodeMissingReturn},
+		},
+		"return inside a conditional loop": {
+			"fnc f(bool b) -> int32 {\n while b {\n return 1i32\n }\n}\nfnc main() -> int32 {\n return f(true)\n}",
+			[]string{diagnostics.CodeMissingReturn},
+		},
+		"code after break": {
+			"fnc main() -> int32 {\n while true {\n break\n def int32 x = 1i32\n }\n return 0i32\n}",
+			[]string{diagnostics.CodeUnreachable, diagnostics.CodeUnused},
+		},
+		"code after continue": {
+			"fnc main() -> int32 {\n def int32 i = 0i32\n while i < 3i32 {\n i = i + 1i32\n continue\n i = 0i32\n }\n return i\n}",
+			[]string{diagnostics.CodeUnreachable},
+		},
+		"code after an if returning on both branches": {
+			"fnc f(bool b) -> int32 {\n if b {\n return 1i32\n } else {\n return 2i32\n }\n return 3i32\n}\nfnc main() -> int32 {\n return f(true)\n}",
+			[]string{diagnostics.CodeUnreachable},
+		},
+		"code after return": {
+			"fnc main() -> int32 {\n return 0i32\n def int32 x = 1i32\n}",
+			[]string{diagnostics.CodeUnreachable, diagnostics.CodeUnused},
+		},
+	}
+
+	runTests(t, tests)
+}
+
+func TestUnused(t *testing.T) {
+	tests := map[string]InputCodes{
+		"everything used": {
+			"import \"io\"\nglobal int g = 1\nfnc f(int x) -> int {\n return x + g\n}\nfnc main() -> int32 {\n println(\"%l\", f(1))\n return 0i32\n}",
+			nil,
+		},
+		"unused variable": {
+			"fnc main() -> int32 {\n def int32 x = 1i32\n return 0i32\n}",
+			[]string{diagnostics.CodeUnused},
+		},
+		"unused parameter": {
+			"fnc f(int32 x) -> int32 {\n return 0i32\n}\nfnc main() -> int32 {\n return f(1i32)\n}",
+			[]string{diagnostics.CodeUnused},
+		},
+		"unused function and global": {
+			"global int g = 1\nfnc f() -> none {\n}\nfnc main() -> int32 {\n return 0i32\n}",
+			[]string{diagnostics.CodeUnused, diagnostics.CodeUnused},
+		},
+		"underscore names": {
+			"global int _g = 1\nfnc _f(int32 _x) -> none {\n}\nfnc main() -> int32 {\n return 0i32\n}",
+			nil,
+		},
+		"exported function": {
+			"export fnc f() -> none {\n}\nfnc main() -> int32 {\n return 0i32\n}",
+			nil,
+		},
+		"unused import": {
+			"import \"io\"\nfnc main() -> int32 {\n return 0i32\n}",
+			[]string{diagnostics.CodeUnusedImport},
+		},
+		"unused variable in a nested block": {
+			"fnc main() -> int32 {\n if true {\n def int32 x = 1i32\n }\n return 0i32\n}",
+			[]string{diagnostics.CodeUnused},
+		},
+		"assigning counts as a use": {
+			"fnc main() -> int32 {\n def int32 x = 1i32\n x = 2i32\n return 0i32\n}",
+			nil,
+		},
+		"shadowed variable used": {
+			"fnc main() -> int32 {\n def int32 x = 1i32\n if true {\n def int32 x = 2i32\n return x\n }\n return 0i32\n}",
+			[]string{diagnostics.CodeUnused},
+		},
+		"variable used by a deferred call": {
+			"import \"ralloc\"\nfnc main() -> int32 {\n def int32* p = malloc(4u64) as int32*\n defer free(p)\n return 0i32\n}",
+			nil,
+		},
+		"function used only by an unused function": {
+			"fnc a() -> none {\n}\nfnc b() -> none {\n a()\n}\nfnc main() -> int32 {\n return 0i32\n}",
+			[]string{diagnostics.CodeUnused},
+		},
+		"underscore local": {
+			"fnc main() -> int32 {\n def int32 _x = 1i32\n return 0i32\n}",
+			nil,
+		},
+	}
+
+	runTests(t, tests)
+
+	t.Run("imported file", func(t *testing.T) {
+		// another file can use the top level declarations, but not the locals
+		input := "global int g = 1\nfnc f() -> none {\n def int32 x = 1i32\n}"
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors) != 0 {
+			t.Fatalf("got parser errors: %v", p.Errors)
+		}
+		c := New()
+		c.Imported = true
+		c.Check(program)
+		if got := codes(c); !slices.Equal(got, []string{diagnostics.CodeUnused}) {
+			t.Errorf("wanted: %v, got: %v (%v %v)", []string{diagnostics.CodeUnused}, got, c.Errors, c.Warnings)
+		}
+	})
+}
+
+func TestGlobalConstants(t *testing.T) {
+	tests := map[string]InputCodes{
+		"int": {
+			"global int g = 1\nfnc main() -> int32 {\n return g as int32\n}",
+			nil,
+		},
+		"negative int": {
+			"global int g = -1\nfnc main() -> int32 {\n return g as int32\n}",
+			nil,
+		},
+		"float, bool and string": {
+			"global float f = 1.5\nglobal bool b = true\nglobal char* s = \"x\"\nfnc main() -> int32 {\n if b {\n return (f as int32) + (*s as int32)\n }\n return 0i32\n}",
+			nil,
+		},
+		"sizeof": {
+			"global uint g = sizeof ?int\nfnc main() -> int32 {\n return g as int32\n}",
+			nil,
+		},
+		"optional": {
+			"global ?int g = some(4)\nglobal ?int n = null\nfnc main() -> int32 {\n return (g orelse (n orelse 0)) as int32\n}",
+			nil,
+		},
+		"struct of constants": {
+			"struct P {\n int a\n int b\n}\nglobal P p = P:{ 1, -2 }\nfnc main() -> int32 {\n return p.b as int32\n}",
+			nil,
+		},
+		"arithmetic": {
+			"global int g = 1 + 2\nfnc main() -> int32 {\n return g as int32\n}",
+			[]string{diagnostics.CodeNonConstant},
+		},
+		"call": {
+			"fnc one() -> int {\n return 1\n}\nglobal int g = one()\nfnc main() -> int32 {\n return g as int32\n}",
+			[]string{diagnostics.CodeNonConstant},
+		},
+		"other global": {
+			"global int a = 1\nglobal int b = a\nfnc main() -> int32 {\n return b as int32\n}",
+			[]string{diagnostics.CodeNonConstant},
+		},
+		"struct with a call": {
+			"struct P {\n int a\n}\nfnc one() -> int {\n return 1\n}\nglobal P p = P:{ one() }\nfnc main() -> int32 {\n return p.a as int32\n}",
+			[]string{diagnostics.CodeNonConstant},
+		},
+		"locals can be anything": {
+			"fnc main() -> int32 {\n def int a = 1\n def int b = a + 1\n return b as int32\n}",
+			nil,
+		},
+	}
+
+	runTests(t, tests)
+}
+
+func codes(c *Checker) []string {
+	var got []string
+	for _, err := range c.Errors {
+		got = append(got, err.Code)
+	}
+	for _, warning := range c.Warnings {
+		got = append(got, warning.Code)
+	}
+	return got
+}
+
+func runTests(t *testing.T, tests map[string]InputCodes) {
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("panicked: %v", r)
+				}
+			}()
+			p := parser.New(lexer.New(test.input))
+			program := p.ParseProgram()
+			if len(p.Errors) != 0 {
+				t.Fatalf("got parser errors: %v", p.Errors)
+			}
+			c := New()
+			c.Check(program)
+			if got := codes(c); !slices.Equal(got, test.codes) {
+				t.Errorf("wanted: %v, got: %v (%v %v)", test.codes, got, c.Errors, c.Warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,585 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"grianlang3/lexer"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type BindgenOpts struct {
+	Output string // path of the .gl3 file, - for stdout, the header's name with .gl3 when empty
+}
+
+// clangNode is a node of clang's json ast dump, with the fields bindgen reads
+type clangNode struct {
+	ID    string   `json:"id"`
+	Kind  string   `json:"kind"`
+	Name  string   `json:"name"`
+	Loc   clangLoc `json:"loc"`
+	Range struct {
+		Begin clangLoc `json:"begin"`
+		End   clangLoc `json:"end"`
+	} `json:"range"`
+	IsImplicit bool `json:"isImplicit"`
+	Type       struct {
+		QualType          string `json:"qualType"`
+		DesugaredQualType string `json:"desugaredQualType"`
+	} `json:"type"`
+	TagUsed             string     `json:"tagUsed"`
+	CompleteDefinition  bool       `json:"completeDefinition"`
+	IsBitfield          bool       `json:"isBitfield"`
+	StorageClass        string     `json:"storageClass"`
+	Variadic            bool       `json:"variadic"`
+	Value               string     `json:"value"`
+	Decl                *clangNode `json:"decl"`
+	OwnedTagDecl        *clangNode `json:"ownedTagDecl"`
+	FixedUnderlyingType *struct {
+		QualType string `json:"qualType"`
+	} `json:"fixedUnderlyingType"`
+	Inner []clangNode `json:"inner"`
+}
+
+// clangLoc is a source location in the dump. to keep the dump small clang leaves out the file when it's the same as
+// that of the location printed before, so it has to be tracked in document order, see trackLoc. locations inside
+// macro expansions are split into where the macro was written and where it was expanded
+type clangLoc struct {
+	File         string    `json:"file"`
+	SpellingLoc  *clangLoc `json:"spellingLoc"`
+	ExpansionLoc *clangLoc `json:"expansionLoc"`
+}
+
+// errOpaque is the error of types gl3 can only point to, like void or structs defined outside the header
+var errOpaque = errors.New("opaque type")
+
+// cBaseTypes are the gl3 types of C's builtin types, for a 64 bit target where long is 64 bits
+var cBaseTypes = map[string]lexer.BaseVarType{
+	"char":                   lexer.Char,
+	"signed char":            lexer.Int8,
+	"unsigned char":          lexer.Uint8,
+	"short":                  lexer.Int16,
+	"short int":              lexer.Int16,
+	"signed short":           lexer.Int16,
+	"unsigned short":         lexer.Uint16,
+	"unsigned short int":     lexer.Uint16,
+	"int":                    lexer.Int32,
+	"signed":                 lexer.Int32,
+	"signed int":             lexer.Int32,
+	"unsigned":               lexer.Uint32,
+	"unsigned int":           lexer.Uint32,
+	"long":                   lexer.Int,
+	"long int":               lexer.Int,
+	"signed long":            lexer.Int,
+	"long long":              lexer.Int,
+	"long long int":          lexer.Int,
+	"unsigned long":          lexer.Uint,
+	"unsigned long int":      lexer.Uint,
+	"unsigned long long":     lexer.Uint,
+	"unsigned long long int": lexer.Uint,
+	"_Bool":                  lexer.Bool,
+	"bool":                   lexer.Bool,
+	"float":                  lexer.Float,
+	"void":                   lexer.Void,
+	"__builtin_va_list":      lexer.VaList,
+}
+
+// RunBindgenCmd generates gl3 declarations for the C header: extern functions and globals, structs, and global
+// consts for enum values. typedefs are resolved to the types they name, as gl3 has none of its own. what can't be
+// translated is reported and left as a comment in the output, rather than failing the whole header
+func RunBindgenCmd(header string, opts *BindgenOpts) error {
+	cmd := exec.Command("clang", "-Xclang", "-ast-dump=json", "-fsyntax-only", header)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("clang failed on %s: %s\n%s", header, err, exitErr.Stderr)
+		}
+		return err
+	}
+	var root clangNode
+	if err := json.Unmarshal(out, &root); err != nil {
+		return fmt.Errorf("reading clang's ast of %s: %w", header, err)
+	}
+
+	b := newBindgen(header, &root)
+	gl3Out := b.generate()
+	for _, skipped := range b.skipped {
+		log.Printf("%s: %s\n", header, skipped)
+	}
+
+	output := opts.Output
+	if output == "" {
+		output = strings.TrimSuffix(filepath.Base(header), filepath.Ext(header)) + ".gl3"
+	}
+	return writeOutput(output, gl3Out)
+}
+
+type bindgen struct {
+	header string
+	// decls are the top level declarations written in the header itself, rather than ones it includes
+	decls []*clangNode
+	// typedefs holds those of every file, as the header's declarations use those of its includes
+	typedefs map[string]*clangNode
+	// anonymous are the names typedefs give anonymous structs and enums, by id
+	anonymous map[string]string
+	enums     map[string]lexer.VarType // enum types by name or id
+	// structs are the names of the header's structs that can be translated
+	structs map[string]bool
+	skipped []string
+}
+
+func newBindgen(header string, root *clangNode) *bindgen {
+	b := &bindgen{
+		header:    header,
+		typedefs:  make(map[string]*clangNode),
+		anonymous: make(map[string]string),
+		enums:     make(map[string]lexer.VarType),
+		structs:   make(map[string]bool),
+	}
+	var file string
+	for i := range root.Inner {
+		decl := &root.Inner[i]
+		declFile := trackLoc(decl, &file)
+		if decl.IsImplicit {
+			continue
+		}
+		if declFile == header {
+			b.decls = append(b.decls, decl)
+		}
+		if decl.Kind == "TypedefDecl" {
+			b.typedefs[decl.Name] = decl
+			if tag := tagDecl(decl); tag != nil && tag.Name == "" {
+				b.anonymous[tag.ID] = decl.Name
+			}
+		}
+	}
+	return b
+}
+
+// trackLoc follows the file of every location in node, returning the file node's own location is in. file is the
+// file of the location printed last
+func trackLoc(node *clangNode, file *string) string {
+	var visit func(loc *clangLoc)
+	visit = func(loc *clangLoc) {
+		if loc.SpellingLoc != nil || loc.ExpansionLoc != nil {
+			if loc.SpellingLoc != nil {
+				visit(loc.SpellingLoc)
+			}
+			if loc.ExpansionLoc != nil {
+				visit(loc.ExpansionLoc)
+			}
+			return
+		}
+		if loc.File != "" {
+			*file = loc.File
+		}
+	}
+	visit(&node.Loc)
+	// a declaration belongs to the file it's expanded in, which is written last
+	declFile := *file
+	visit(&node.Range.Begin)
+	visit(&node.Range.End)
+	for i := range node.Inner {
+		trackLoc(&node.Inner[i], file)
+	}
+	return declFile
+}
+
+// tagDecl is the struct or enum declared by or named by a typedef
+func tagDecl(typedef *clangNode) *clangNode {
+	var find func(n *clangNode) *clangNode
+	find = func(n *clangNode) *clangNode {
+		for _, d := range []*clangNode{n.OwnedTagDecl, n.Decl} {
+			if d != nil && (d.Kind == "RecordDecl" || d.Kind == "EnumDecl") {
+				return d
+			}
+		}
+		for i := range n.Inner {
+			if d := find(&n.Inner[i]); d != nil {
+				return d
+			}
+		}
+		return nil
+	}
+	return find(typedef)
+}
+
+// skip reports that what of the header couldn't be translated, returning a comment to leave in its place
+func (b *bindgen) skip(what string, err error) string {
+	b.skipped = append(b.skipped, fmt.Sprintf("skipping %s, %s", what, err))
+	return fmt.Sprintf("// skipped %s: %s", what, err)
+}
+
+func (b *bindgen) generate() string {
+	var consts, structs, externs []string
+
+	// enums go first, as struct fields and parameters of enum types need their types
+	for _, decl := range b.decls {
+		if decl.Kind == "EnumDecl" {
+			consts = append(consts, b.enum(decl)...)
+		}
+	}
+
+	// a struct can only be translated if the structs it holds by value can be, so keep dropping those that can't
+	// until none are left
+	for _, decl := range b.decls {
+		if decl.Kind == "RecordDecl" && decl.CompleteDefinition && decl.TagUsed == "struct" && b.recordName(decl) != "" {
+			b.structs[b.recordName(decl)] = true
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, decl := range b.decls {
+			name := b.recordName(decl)
+			if decl.Kind != "RecordDecl" || !b.structs[name] {
+				continue
+			}
+			if _, err := b.record(decl); err != nil {
+				delete(b.structs, name)
+				changed = true
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, decl := range b.decls {
+		switch decl.Kind {
+		case "RecordDecl":
+			name := b.recordName(decl)
+			if !decl.CompleteDefinition || name == "" {
+				continue
+			}
+			if decl.TagUsed != "struct" {
+				structs = append(structs, b.skip(decl.TagUsed+" "+name, errors.New("gl3 has no unions")))
+				continue
+			}
+			def, err := b.record(decl)
+			if err != nil {
+				structs = append(structs, b.skip("struct "+name, err))
+				continue
+			}
+			structs = append(structs, def)
+		case "FunctionDecl":
+			if seen[decl.Name] {
+				continue
+			}
+			seen[decl.Name] = true
+			def, err := b.function(decl)
+			if err != nil {
+				externs = append(externs, b.skip("fnc "+decl.Name, err))
+				continue
+			}
+			externs = append(externs, def)
+		case "VarDecl":
+			if decl.StorageClass != "extern" || seen[decl.Name] {
+				continue
+			}
+			seen[decl.Name] = true
+			vt, err := b.resolve(decl.Type.QualType, decl.Type.DesugaredQualType)
+			if err == nil {
+				err = keywordName(decl.Name)
+			}
+			if err != nil {
+				externs = append(externs, b.skip("global "+decl.Name, err))
+				continue
+			}
+			externs = append(externs, fmt.Sprintf("extern global %s %s", getTypeString(vt), decl.Name))
+		case "TypedefDecl":
+			// typedefs are only reported, uses of them are what's translated
+			if _, err := b.resolveType(decl.Name); err != nil && err != errOpaque {
+				b.skip("uses of typedef "+decl.Name, err)
+			}
+		}
+	}
+
+	var gl3Out strings.Builder
+	fmt.Fprintf(&gl3Out, "// generated by gl3 bindgen from %s, do not edit\n", b.header)
+	// bindings are used piecemeal, the enum values a program doesn't use aren't worth a warning each
+	gl3Out.WriteString("// gl3:ignore-file unused\n")
+	for _, section := range []string{strings.Join(consts, "\n"), strings.Join(structs, "\n\n"), strings.Join(externs, "\n")} {
+		if section != "" {
+			gl3Out.WriteString("\n")
+			gl3Out.WriteString(section)
+			gl3Out.WriteString("\n")
+		}
+	}
+	return gl3Out.String()
+}
+
+func (b *bindgen) recordName(decl *clangNode) string {
+	if decl.Name != "" {
+		return decl.Name
+	}
+	return b.anonymous[decl.ID]
+}
+
+// enum declares the values of an enum as global consts, as gl3 has no enums. the enum type itself becomes its
+// underlying type, int32 unless it's given or the values don't fit
+func (b *bindgen) enum(decl *clangNode) []string {
+	vt := lexer.VarType{Base: lexer.Int32}
+	if decl.FixedUnderlyingType != nil {
+		underlying, err := b.resolve(decl.FixedUnderlyingType.QualType, "")
+		_, isInt := literalSuffixes[underlying.Base]
+		if err != nil || underlying.Pointer != 0 || !isInt {
+			return []string{b.skip("enum "+b.enumName(decl), fmt.Errorf("%s isn't an integer type gl3 has", decl.FixedUnderlyingType.QualType))}
+		}
+		vt = underlying
+	}
+
+	var names []string
+	var values []int64
+	var next int64
+	for _, constDecl := range decl.Inner {
+		if constDecl.Kind != "EnumConstantDecl" {
+			continue
+		}
+		if value, ok := constantValue(&constDecl); ok {
+			next = value
+		}
+		names = append(names, constDecl.Name)
+		values = append(values, next)
+		if decl.FixedUnderlyingType == nil && (next < -1<<31 || next > 1<<31-1) {
+			vt = lexer.VarType{Base: lexer.Int}
+		}
+		next++
+	}
+
+	b.enums[decl.ID] = vt
+	if decl.Name != "" {
+		b.enums[decl.Name] = vt
+	}
+	consts := []string{fmt.Sprintf("// enum %s", b.enumName(decl))}
+	for i, name := range names {
+		consts = append(consts, fmt.Sprintf("global const %s %s = %d%s", getTypeString(vt), name, values[i], literalSuffixes[vt.Base]))
+	}
+	return consts
+}
+
+func (b *bindgen) enumName(decl *clangNode) string {
+	if name := b.recordName(decl); name != "" {
+		return name
+	}
+	return "(anonymous)"
+}
+
+// constantValue is the value clang worked out for an enum constant's initializer, if it has one
+func constantValue(node *clangNode) (int64, bool) {
+	if node.Kind == "ConstantExpr" && node.Value != "" {
+		value, err := strconv.ParseInt(node.Value, 10, 64)
+		if err == nil {
+			return value, true
+		}
+		// values above the int64 range wrap around, as they do in a 64 bit enum
+		uvalue, err := strconv.ParseUint(node.Value, 10, 64)
+		return int64(uvalue), err == nil
+	}
+	for i := range node.Inner {
+		if value, ok := constantValue(&node.Inner[i]); ok {
+			return value, true
+		}
+	}
+	return 0, false
+}
+
+func (b *bindgen) record(decl *clangNode) (string, error) {
+	if err := keywordName(b.recordName(decl)); err != nil {
+		return "", err
+	}
+	var fields strings.Builder
+	fmt.Fprintf(&fields, "struct %s {\n", b.recordName(decl))
+	for _, field := range decl.Inner {
+		if field.Kind != "FieldDecl" {
+			continue
+		}
+		if field.IsBitfield {
+			return "", fmt.Errorf("bit field %s has no gl3 equivalent", field.Name)
+		}
+		vt, err := b.resolve(field.Type.QualType, field.Type.DesugaredQualType)
+		if err != nil {
+			return "", fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		fmt.Fprintf(&fields, "    %s %s\n", getTypeString(vt), gl3Name(field.Name))
+	}
+	fields.WriteString("}")
+	return fields.String(), nil
+}
+
+func (b *bindgen) function(decl *clangNode) (string, error) {
+	if decl.StorageClass == "static" {
+		return "", errors.New("static functions aren't linked against")
+	}
+	if err := keywordName(decl.Name); err != nil {
+		return "", err
+	}
+	// the return type is what's left of the function type after the parameter list
+	qualType, desugared := decl.Type.QualType, decl.Type.DesugaredQualType
+	if idx := strings.Index(qualType, "("); idx >= 0 {
+		if strings.HasPrefix(qualType[idx:], "(*") {
+			return "", errors.New("it returns a function pointer, which has no gl3 equivalent")
+		}
+		qualType = qualType[:idx]
+	}
+	if idx := strings.Index(desugared, "("); idx >= 0 {
+		desugared = desugared[:idx]
+	}
+	ret, err := b.resolve(qualType, desugared)
+	if err != nil {
+		return "", fmt.Errorf("return type: %w", err)
+	}
+	if ret.IsStructType && ret.Pointer == 0 {
+		return "", errors.New("it returns a struct, which gl3 and C pass differently")
+	}
+
+	var params []string
+	for _, param := range decl.Inner {
+		if param.Kind != "ParmVarDecl" {
+			continue
+		}
+		name := param.Name
+		if name == "" {
+			name = fmt.Sprintf("arg%d", len(params))
+		}
+		vt, err := b.resolve(param.Type.QualType, param.Type.DesugaredQualType)
+		if err != nil {
+			return "", fmt.Errorf("parameter %s: %w", name, err)
+		}
+		if vt.IsStructType && vt.Pointer == 0 {
+			return "", fmt.Errorf("parameter %s is a struct, which gl3 and C pass differently", name)
+		}
+		params = append(params, getTypeString(vt)+" "+gl3Name(name))
+	}
+	if decl.Variadic {
+		params = append(params, "...")
+	}
+	return fmt.Sprintf("extern fnc %s(%s) -> %s", decl.Name, strings.Join(params, ", "), getTypeString(ret)), nil
+}
+
+// resolve maps a C type onto a gl3 type, trying the type as written and then with its typedefs expanded. pointers to
+// void and to structs gl3 doesn't know become int8 pointers like malloc's, they're all the same to the abi
+func (b *bindgen) resolve(qualType string, desugared string) (lexer.VarType, error) {
+	vt, err := b.resolveType(qualType)
+	if err != nil && desugared != "" {
+		if dvt, derr := b.resolveType(desugared); derr == nil {
+			return dvt, nil
+		}
+	}
+	if err == errOpaque {
+		return lexer.VarType{}, errors.New("struct or void values have no gl3 type, only pointers to them")
+	}
+	return vt, err
+}
+
+func (b *bindgen) resolveType(qualType string) (lexer.VarType, error) {
+	switch {
+	case strings.Contains(qualType, "(*"):
+		return lexer.VarType{}, fmt.Errorf("function pointer %s has no gl3 equivalent", qualType)
+	case strings.Contains(qualType, "["):
+		return lexer.VarType{}, fmt.Errorf("array %s has no gl3 equivalent", qualType)
+	case strings.Contains(qualType, "("):
+		return lexer.VarType{}, fmt.Errorf("%s has no gl3 equivalent", qualType)
+	}
+	var words []string
+	for _, word := range strings.Fields(strings.ReplaceAll(qualType, "*", " * ")) {
+		switch word {
+		case "const", "volatile", "restrict", "__restrict", "_Nonnull", "_Nullable":
+			continue
+		}
+		words = append(words, word)
+	}
+	var pointer uint8
+	for len(words) != 0 && words[len(words)-1] == "*" {
+		pointer++
+		words = words[:len(words)-1]
+	}
+	base := strings.Join(words, " ")
+	if strings.Contains(base, "*") {
+		return lexer.VarType{}, fmt.Errorf("%s has no gl3 equivalent", qualType)
+	}
+
+	vt, err := b.resolveBase(base)
+	if err == errOpaque && pointer != 0 {
+		return lexer.VarType{Base: lexer.Int8, Pointer: pointer}, nil
+	}
+	if err != nil {
+		return vt, err
+	}
+	if vt.Base == lexer.Void && !vt.IsStructType && pointer != 0 {
+		vt.Base = lexer.Int8
+	}
+	vt.Pointer += pointer
+	return vt, nil
+}
+
+func (b *bindgen) resolveBase(base string) (lexer.VarType, error) {
+	if bvt, ok := cBaseTypes[base]; ok {
+		return lexer.VarType{Base: bvt}, nil
+	}
+	switch base {
+	case "double", "long double":
+		return lexer.VarType{}, fmt.Errorf("%s has no gl3 equivalent, gl3 floats are 32 bit", base)
+	}
+	if name, ok := strings.CutPrefix(base, "struct "); ok {
+		if b.structs[name] {
+			return lexer.VarType{IsStructType: true, StructName: name}, nil
+		}
+		return lexer.VarType{}, errOpaque
+	}
+	if _, ok := strings.CutPrefix(base, "union "); ok {
+		return lexer.VarType{}, errOpaque
+	}
+	if name, ok := strings.CutPrefix(base, "enum "); ok {
+		if vt, ok := b.enums[name]; ok {
+			return vt, nil
+		}
+		return lexer.VarType{Base: lexer.Int32}, nil
+	}
+	if typedef, ok := b.typedefs[base]; ok {
+		if tag := tagDecl(typedef); tag != nil && tag.Name == "" {
+			if tag.Kind == "EnumDecl" {
+				if vt, ok := b.enums[tag.ID]; ok {
+					return vt, nil
+				}
+				return lexer.VarType{Base: lexer.Int32}, nil
+			}
+			if b.structs[base] {
+				return lexer.VarType{IsStructType: true, StructName: base}, nil
+			}
+			return lexer.VarType{}, errOpaque
+		}
+		vt, err := b.resolveType(typedef.Type.QualType)
+		if err != nil && err != errOpaque {
+			// a typedef of a typedef gl3 can't resolve, like a compiler builtin, might still expand to one it can
+			for i := range typedef.Inner {
+				if desugared := typedef.Inner[i].Type.QualType; desugared != "" && desugared != typedef.Type.QualType {
+					if dvt, derr := b.resolveType(desugared); derr == nil {
+						return dvt, nil
+					}
+				}
+			}
+		}
+		return vt, err
+	}
+	return lexer.VarType{}, fmt.Errorf("unknown type %s", base)
+}
+
+// gl3Name renames C names that are gl3 keywords, by appending an underscore
+func gl3Name(name string) string {
+	if tok := lexer.New(name).NextToken(); tok.Type != lexer.IDENTIFIER || tok.Literal != name {
+		return name + "_"
+	}
+	return name
+}
+
+// keywordName rejects the names of structs, functions and globals that are gl3 keywords. unlike fields and parameters
+// they can't be renamed: functions and globals are linked against by name, and a renamed struct wouldn't match the C name programs use
+func keywordName(name string) error {
+	if gl3Name(name) != name {
+		return fmt.Errorf("%s is a gl3 keyword", name)
+	}
+	return nil
+}
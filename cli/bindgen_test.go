@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"encoding/json"
+	"grianlang3/lexer"
+	"strings"
+	"testing"
+)
+
+// dumpBindgen sets up bindgen for a header whose top level declarations are those in clang's json ast dump decls
+func dumpBindgen(t *testing.T, decls string) *bindgen {
+	var root clangNode
+	if err := json.Unmarshal([]byte(`{"kind":"TranslationUnitDecl","inner":[`+decls+`]}`), &root); err != nil {
+		t.Fatalf("bad dump: %v", err)
+	}
+	return newBindgen("h.h", &root)
+}
+
+func TestBindgenResolve(t *testing.T) {
+	b := dumpBindgen(t, `
+		{"kind":"TypedefDecl","name":"size_t","loc":{"file":"h.h"},"type":{"qualType":"unsigned long"}},
+		{"kind":"TypedefDecl","name":"handle_t","type":{"qualType":"struct handle *"}},
+		{"kind":"TypedefDecl","name":"Vec","type":{"qualType":"struct Vec"},
+			"inner":[{"kind":"ElaboratedType","ownedTagDecl":{"id":"0x1","kind":"RecordDecl","name":""}}]},
+		{"kind":"TypedefDecl","name":"Color","type":{"qualType":"enum Color"},
+			"inner":[{"kind":"ElaboratedType","ownedTagDecl":{"id":"0x2","kind":"EnumDecl","name":""}}]},
+		{"kind":"TypedefDecl","name":"callback","type":{"qualType":"void (*)(int)"}},
+		{"kind":"TypedefDecl","name":"my_size","type":{"qualType":"__size_type"},
+			"inner":[{"kind":"BuiltinType","type":{"qualType":"unsigned long"}}]}`)
+	b.structs["Point"] = true
+	b.structs["Vec"] = true
+	b.enums["0x2"] = lexer.VarType{Base: lexer.Int}
+
+	// output is the gl3 type, empty when the type can't be translated
+	tests := map[string]InputOutput{
+		"int":                      {"int", "int32"},
+		"long":                     {"long", "int"},
+		"unsigned long long":       {"unsigned long long", "uint"},
+		"signed char":              {"signed char", "int8"},
+		"char":                     {"char", "char"},
+		"bool":                     {"_Bool", "bool"},
+		"float":                    {"float", "float"},
+		"double":                   {"double", ""},
+		"qualified pointer":        {"const char *", "char*"},
+		"pointer to const pointer": {"char *const *", "char**"},
+		"restrict pointer":         {"int *__restrict", "int32*"},
+		"void pointer":             {"void *", "int8*"},
+		"array":                    {"int [4]", ""},
+		"function pointer":         {"void (*)(int)", ""},
+		"known struct":             {"struct Point", "Point"},
+		"known struct pointer":     {"struct Point *", "Point*"},
+		"unknown struct pointer":   {"struct Other *", "int8*"},
+		"unknown struct":           {"struct Other", ""},
+		"union pointer":            {"union U *", "int8*"},
+		"unknown enum":             {"enum E", "int32"},
+		"typedef":                  {"size_t", "uint"},
+		"typedef pointer":          {"size_t *", "uint*"},
+		"typedef of a pointer":     {"handle_t", "int8*"},
+		"anonymous struct":         {"Vec", "Vec"},
+		"anonymous struct pointer": {"Vec *", "Vec*"},
+		"anonymous enum":           {"Color", "int"},
+		"function pointer typedef": {"callback", ""},
+		"desugared typedef":        {"my_size", "uint"},
+		"unknown type":             {"widget_t", ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := ""
+			if vt, err := b.resolve(test.input, ""); err == nil {
+				got = getTypeString(vt)
+			}
+			if got != test.output {
+				t.Errorf("wanted: %q, got: %q", test.output, got)
+			}
+		})
+	}
+}
+
+func TestBindgenGenerate(t *testing.T) {
+	intField := func(name string) string {
+		return `{"kind":"FieldDecl","name":"` + name + `","type":{"qualType":"int"}}`
+	}
+	// output is what bindgen writes after its header comments
+	tests := map[string]InputOutput{
+		"enum": {
+			`{"kind":"EnumDecl","id":"0x1","name":"Mode","loc":{"file":"h.h"},"inner":[
+				{"kind":"EnumConstantDecl","name":"READ"},
+				{"kind":"EnumConstantDecl","name":"WRITE","inner":[{"kind":"ConstantExpr","value":"4"}]},
+				{"kind":"EnumConstantDecl","name":"APPEND"}]}`,
+			"// enum Mode\nglobal const int32 READ = 0i32\nglobal const int32 WRITE = 4i32\nglobal const int32 APPEND = 5i32",
+		},
+		"enum past int32": {
+			`{"kind":"EnumDecl","id":"0x1","name":"Big","loc":{"file":"h.h"},"inner":[
+				{"kind":"EnumConstantDecl","name":"HUGE","inner":[{"kind":"ConstantExpr","value":"4294967296"}]}]}`,
+			"// enum Big\nglobal const int HUGE = 4294967296",
+		},
+		"enum with an underlying type": {
+			`{"kind":"EnumDecl","id":"0x1","name":"Small","loc":{"file":"h.h"},"fixedUnderlyingType":{"qualType":"unsigned char"},
+				"inner":[{"kind":"EnumConstantDecl","name":"ONE","inner":[{"kind":"ConstantExpr","value":"1"}]}]}`,
+			"// enum Small\nglobal const uint8 ONE = 1u8",
+		},
+		"enum with a float underlying type": {
+			`{"kind":"EnumDecl","id":"0x1","name":"F","loc":{"file":"h.h"},"fixedUnderlyingType":{"qualType":"float"},"inner":[]}`,
+			"// skipped enum F: float isn't an integer type gl3 has",
+		},
+		"struct": {
+			`{"kind":"RecordDecl","name":"Point","tagUsed":"struct","completeDefinition":true,"loc":{"file":"h.h"},
+				"inner":[` + intField("x") + `,` + intField("def") + `]}`,
+			"struct Point {\n    int32 x\n    int32 def_\n}",
+		},
+		"forward declared struct": {
+			`{"kind":"RecordDecl","name":"Point","tagUsed":"struct","loc":{"file":"h.h"}}`,
+			"",
+		},
+		"struct with a bit field": {
+			`{"kind":"RecordDecl","name":"Flags","tagUsed":"struct","completeDefinition":true,"loc":{"file":"h.h"},
+				"inner":[{"kind":"FieldDecl","name":"on","isBitfield":true,"type":{"qualType":"int"}}]}`,
+			"// skipped struct Flags: bit field on has no gl3 equivalent",
+		},
+		"struct holding a skipped struct": {
+			`{"kind":"RecordDecl","name":"Flags","tagUsed":"struct","completeDefinition":true,"loc":{"file":"h.h"},
+				"inner":[{"kind":"FieldDecl","name":"on","isBitfield":true,"type":{"qualType":"int"}}]},
+			{"kind":"RecordDecl","name":"Config","tagUsed":"struct","completeDefinition":true,
+				"inner":[{"kind":"FieldDecl","name":"flags","type":{"qualType":"struct Flags"}}]}`,
+			"// skipped struct Flags: bit field on has no gl3 equivalent\n\n" +
+				"// skipped struct Config: field flags: struct or void values have no gl3 type, only pointers to them",
+		},
+		"union": {
+			`{"kind":"RecordDecl","name":"Value","tagUsed":"union","completeDefinition":true,"loc":{"file":"h.h"},
+				"inner":[` + intField("i") + `]}`,
+			"// skipped union Value: gl3 has no unions",
+		},
+		"struct named after a keyword": {
+			`{"kind":"RecordDecl","name":"global","tagUsed":"struct","completeDefinition":true,"loc":{"file":"h.h"},
+				"inner":[` + intField("x") + `]}`,
+			"// skipped struct global: global is a gl3 keyword",
+		},
+		"function": {
+			`{"kind":"FunctionDecl","name":"log_msg","loc":{"file":"h.h"},"type":{"qualType":"int (const char *, int, ...)"},"variadic":true,
+				"inner":[{"kind":"ParmVarDecl","name":"fmt","type":{"qualType":"const char *"}},
+					{"kind":"ParmVarDecl","type":{"qualType":"int"}}]}`,
+			"extern fnc log_msg(char* fmt, int32 arg1, ...) -> int32",
+		},
+		"parameter named after a keyword": {
+			`{"kind":"FunctionDecl","name":"set","loc":{"file":"h.h"},"type":{"qualType":"void (int)"},
+				"inner":[{"kind":"ParmVarDecl","name":"struct","type":{"qualType":"int"}}]}`,
+			"extern fnc set(int32 struct_) -> none",
+		},
+		"function named after a keyword": {
+			`{"kind":"FunctionDecl","name":"defer","loc":{"file":"h.h"},"type":{"qualType":"void (void)"}}`,
+			"// skipped fnc defer: defer is a gl3 keyword",
+		},
+		"static function": {
+			`{"kind":"FunctionDecl","name":"helper","storageClass":"static","loc":{"file":"h.h"},"type":{"qualType":"void (void)"}}`,
+			"// skipped fnc helper: static functions aren't linked against",
+		},
+		"function returning a struct": {
+			`{"kind":"RecordDecl","name":"Point","tagUsed":"struct","completeDefinition":true,"loc":{"file":"h.h"},
+				"inner":[` + intField("x") + `]},
+			{"kind":"FunctionDecl","name":"origin","type":{"qualType":"struct Point (void)"}}`,
+			"struct Point {\n    int32 x\n}\n\n// skipped fnc origin: it returns a struct, which gl3 and C pass differently",
+		},
+		"global": {
+			`{"kind":"VarDecl","name":"counter","storageClass":"extern","loc":{"file":"h.h"},"type":{"qualType":"int"}}`,
+			"extern global int32 counter",
+		},
+		"global named after a keyword": {
+			`{"kind":"VarDecl","name":"export","storageClass":"extern","loc":{"file":"h.h"},"type":{"qualType":"int"}}`,
+			"// skipped global export: export is a gl3 keyword",
+		},
+		"declaration of an included file": {
+			`{"kind":"FunctionDecl","name":"puts","loc":{"file":"stdio.h"},"type":{"qualType":"int (const char *)"}}`,
+			"",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			out := dumpBindgen(t, test.input).generate()
+			_, got, _ := strings.Cut(out, "// gl3:ignore-file unused\n")
+			got = strings.TrimSpace(got)
+			if got != test.output {
+				t.Errorf("wanted: %q, got: %q", test.output, got)
+			}
+		})
+	}
+}
@@ -2,14 +2,20 @@ package cli
 
 import (
 	"embed"
+	"errors"
 	"fmt"
 	"grianlang3/checker"
+	"grianlang3/diagnostics"
 	"grianlang3/emitter"
 	"grianlang3/lexer"
 	"grianlang3/parser"
+	"io"
+	"io/fs"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -17,77 +23,119 @@ type BuildOpts struct {
 	KeepLL      bool
 	Dbg         bool
 	NoExecBuild bool
+	Output      string   // path of the output, - for stdout, derived from the input file or out for executables when empty
+	Emit        string   // one of EmitKinds, exe when empty
+	OptLevel    string   // one of OptLevels, passed to clang as -O, 0 when empty
+	Target      string   // llvm target triple to build for, the host when empty
+	Libs        []string // passed to clang as -l
+	LibDirs     []string // passed to clang as -L
+	// DiagnosticsFormat is one of diagnostics.Formats
+	DiagnosticsFormat string
+	Warnings          []string // -W flag values, see diagnostics.NewPolicy
 }
 
-func RunBuildCmd(builtinFs embed.FS, files []string, opts *BuildOpts) error {
-	// TODO: really should do this in /tmp
-	if err := os.RemoveAll("./lltemp"); err != nil {
+// EmitKinds are what a build can stop at and output: the AST, llvm ir as text or bitcode, assembly, an object file,
+// a linked executable, or a static or shared library
+var EmitKinds = []string{"ast", "ll", "bc", "asm", "obj", "exe", "staticlib", "sharedlib"}
+
+// OptLevels are the optimization levels clang is run with, s optimizing for size
+var OptLevels = []string{"0", "1", "2", "3", "s"}
+
+// emitExts are the extensions of the per file outputs, the other kinds link every file into one output
+var emitExts = map[string]string{"ast": "ast", "ll": "ll", "bc": "bc", "asm": "s", "obj": "o"}
+
+// emitClangFlags are how clang turns a .ll file into each kind of output
+var emitClangFlags = map[string][]string{"bc": {"-c", "-emit-llvm"}, "asm": {"-S"}, "obj": {"-c"}}
+
+func RunBuildCmd(builtinFs embed.FS, files []string, opts *BuildOpts) (err error) {
+	emit := opts.Emit
+	if emit == "" {
+		emit = "exe"
+	}
+	if !slices.Contains(EmitKinds, emit) {
+		return fmt.Errorf("unknown emit kind %q, expected one of %v", emit, EmitKinds)
+	}
+	if opts.OptLevel != "" && !slices.Contains(OptLevels, opts.OptLevel) {
+		return fmt.Errorf("unknown optimization level -O%s, expected one of %v", opts.OptLevel, OptLevels)
+	}
+	if _, perFile := emitExts[emit]; perFile && opts.Output != "" && len(files) > 1 {
+		return fmt.Errorf("-o can only be used with a single input file when emitting %s", emit)
+	}
+	target := emitter.HostTarget
+	if opts.Target != "" {
+		if target, err = emitter.LookupTarget(opts.Target); err != nil {
+			return err
+		}
+	}
+	policy, err := diagnostics.NewPolicy(opts.Warnings)
+	if err != nil {
 		return err
 	}
-	if err := os.Mkdir("./lltemp", os.ModePerm); err != nil {
+	diags, err := diagnostics.NewWriter(opts.DiagnosticsFormat)
+	if err != nil {
 		return err
 	}
+	// json and sarif are written as one document, whether or not the build gets to the end
+	defer func() {
+		if flushErr := diags.Flush(); flushErr != nil && err == nil {
+			err = flushErr
+		}
+	}()
+
+	workDir, err := os.MkdirTemp("", "gl3-build-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if opts.KeepLL {
+			log.Printf("kept .ll files in %s\n", workDir)
+		} else if rmErr := os.RemoveAll(workDir); rmErr != nil && err == nil {
+			err = rmErr
+		}
+	}()
+
 	var llFiles []string
 	builtinModules := map[string]struct{}{}
-	for _, file := range files {
+	imported := importedFiles(files)
+	for i, file := range files {
 		input, err := os.ReadFile(file)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
+		diags.AddSource(file, string(input))
 
-		l := lexer.New(string(input))
-		p := parser.New(l)
-		program := p.ParseProgram()
-		err = safeRun(func() {
-			if opts.Dbg {
-				log.Printf("%s: %s\n", file, program.String())
-			}
-		})
+		program, info, err := checkFile(file, string(input), diags, policy, isImported(imported, file), opts.Dbg)
 		if err != nil {
 			return err
 		}
-		if len(p.Errors) != 0 {
-			for _, err := range p.Errors {
-				log.Printf("parser error: %s:%s\n", file, err.String())
-			}
-			return fmt.Errorf("%s: exiting after parser errrors\n", file)
-		}
-		c := checker.New()
-		c.Check(program)
-		if len(c.Errors) != 0 {
-			for _, err := range c.Errors {
-				log.Printf("checker warning: %s:%s\n", file, err.String())
+		if emit == "ast" {
+			if err := writeOutput(outputFor(file, emit, opts), program.String()+"\n"); err != nil {
+				return fmt.Errorf("%s: %w\n", file, err)
 			}
+			continue
 		}
 
-		e := emitter.New()
-		err = safeRun(func() {
-			e.Emit(program)
-		})
-		if len(e.Errors) != 0 {
-			for _, err := range e.Errors {
-				log.Printf("compiler error: %s:%s\n", file, err.String())
-			}
-			return fmt.Errorf("compiler errors\n")
-		}
+		e, llvmIr, err := emitFile(file, program, info, target, diags)
 		if err != nil {
-			log.Printf("%s: recovered emitting llvm ir: %s\n", file, err)
-			return fmt.Errorf("compiler panic\n")
+			return err
 		}
-		llvmIr := e.Module()
-
-		fileName := fmt.Sprintf("./lltemp/%s.ll", file)
-		llFile, err := os.Create(fileName)
-		if err != nil {
-			return fmt.Errorf("%s: %w\n", file, err)
+		if emit == "ll" {
+			if err := writeOutput(outputFor(file, emit, opts), llvmIr); err != nil {
+				return fmt.Errorf("%s: %w\n", file, err)
+			}
+			continue
 		}
-		_, err = fmt.Fprintf(llFile, "%s", llvmIr)
-		if err != nil {
+
+		// numbered so files of the same name from different directories don't clash
+		fileName := filepath.Join(workDir, fmt.Sprintf("%d-%s.ll", i, filepath.Base(file)))
+		if err := os.WriteFile(fileName, []byte(llvmIr), 0o644); err != nil {
 			return fmt.Errorf("%s: %w\n", file, err)
 		}
-		err = llFile.Close()
-		if err != nil {
-			return fmt.Errorf("%s: %w\n", file, err)
+		if flags, ok := emitClangFlags[emit]; ok {
+			if err := runClang(append(slices.Clone(flags), fileName, "-o", outputFor(file, emit, opts)), opts); err != nil {
+				return err
+			}
+			continue
 		}
 
 		llFiles = append(llFiles, fileName)
@@ -96,53 +144,217 @@ func RunBuildCmd(builtinFs embed.FS, files []string, opts *BuildOpts) error {
 			builtinModules[builtinModule] = struct{}{}
 		}
 	}
+	if _, perFile := emitExts[emit]; perFile {
+		return nil
+	}
 
 	for mod, _ := range builtinModules {
 		// kinda dodgy fix but realistically its going to be one or two modules that vendor c stuff directly so no point bothering
 		if mod == "ralloc.ll" {
 			continue
 		}
+		// the prebuilt .ll files are for the host, so the C they're built from is compiled for anything else
+		if opts.Target != "" {
+			mod = strings.TrimSuffix(mod, ".ll") + ".c"
+		}
 		modText, err := builtinFs.ReadFile(fmt.Sprintf("builtins/%s", mod))
 		if err != nil {
 			return fmt.Errorf("failed to read %s from builtin fs: %w\n", mod, err)
 		}
 
-		fileName := fmt.Sprintf("./lltemp/%s", mod)
-		llFile, err := os.Create(fileName)
-		if err != nil {
-			return fmt.Errorf("failed to create %s: %w\n", llFile.Name(), err)
-		}
-		_, err = fmt.Fprintf(llFile, "%s", modText)
-		if err != nil {
-			return fmt.Errorf("failed to write %s: %w\n", llFile.Name(), err)
-		}
-		err = llFile.Close()
-		if err != nil {
-			return fmt.Errorf("failed to close %s: %w\n", llFile.Name(), err)
+		fileName := filepath.Join(workDir, mod)
+		if err := os.WriteFile(fileName, modText, 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w\n", fileName, err)
 		}
 		llFiles = append(llFiles, fileName)
 	}
 
-	llFiles = append(llFiles, "-o", "out")
-	if !opts.NoExecBuild {
-		cmd := exec.Command("clang", llFiles...)
-		if opts.Dbg {
-			fmt.Printf("executing: %s\n", strings.Join(cmd.Args, " "))
-		}
-		out, err := cmd.CombinedOutput()
-		if err != nil {
-			return fmt.Errorf("error in clang exec, out: %s, err: %w", out, err)
+	if emit == "staticlib" {
+		return archive(llFiles, libOutput(files, ".a", opts), opts)
+	}
+	output := opts.Output
+	if output == "" {
+		output = "out"
+	}
+	if emit == "sharedlib" {
+		llFiles = append([]string{"-shared", "-fPIC"}, llFiles...)
+		output = libOutput(files, ".so", opts)
+	}
+	for _, dir := range opts.LibDirs {
+		llFiles = append(llFiles, "-L"+dir)
+	}
+	for _, lib := range opts.Libs {
+		llFiles = append(llFiles, "-l"+lib)
+	}
+	llFiles = append(llFiles, "-o", output)
+	return runClang(llFiles, opts)
+}
+
+// archive compiles the .ll and .c files of a build to objects and bundles them into a static library. they're
+// compiled position independent so the library can be linked into shared libraries too
+func archive(files []string, output string, opts *BuildOpts) error {
+	arArgs := []string{"rcs", output}
+	for _, file := range files {
+		obj := strings.TrimSuffix(file, filepath.Ext(file)) + ".o"
+		if err := runClang([]string{"-c", "-fPIC", file, "-o", obj}, opts); err != nil {
+			return err
 		}
+		arArgs = append(arArgs, obj)
 	}
-	if !opts.KeepLL {
-		if err := os.RemoveAll("./lltemp"); err != nil {
+	// ar adds to an existing archive rather than replacing it
+	if !opts.NoExecBuild {
+		if err := os.Remove(output); err != nil && !errors.Is(err, fs.ErrNotExist) {
 			return err
 		}
 	}
+	return runCommand("ar", arArgs, opts)
+}
 
+// libOutput is where a library goes, -o if it was given or else lib followed by the first file's name
+func libOutput(files []string, ext string, opts *BuildOpts) string {
+	if opts.Output != "" {
+		return opts.Output
+	}
+	return "lib" + strings.TrimSuffix(filepath.Base(files[0]), ".gl3") + ext
+}
+
+// outputFor is where the output of emitting file as emit goes, -o if it was given or else the file's name with the
+// extension of emit in the current directory
+func outputFor(file string, emit string, opts *BuildOpts) string {
+	if opts.Output != "" {
+		return opts.Output
+	}
+	return strings.TrimSuffix(filepath.Base(file), ".gl3") + "." + emitExts[emit]
+}
+
+// writeOutput writes text to path, or stdout when path is -
+func writeOutput(path string, text string) error {
+	if path == "-" {
+		_, err := io.WriteString(os.Stdout, text)
+		return err
+	}
+	return os.WriteFile(path, []byte(text), 0o644)
+}
+
+func runClang(args []string, opts *BuildOpts) error {
+	if opts.NoExecBuild {
+		return nil
+	}
+	if opts.OptLevel != "" {
+		args = append([]string{"-O" + opts.OptLevel}, args...)
+	}
+	if opts.Target != "" {
+		args = append([]string{"--target=" + opts.Target}, args...)
+	}
+	return runCommand("clang", args, opts)
+}
+
+func runCommand(name string, args []string, opts *BuildOpts) error {
+	if opts.NoExecBuild {
+		return nil
+	}
+	cmd := exec.Command(name, args...)
+	if opts.Dbg {
+		fmt.Printf("executing: %s\n", strings.Join(cmd.Args, " "))
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error in %s exec, out: %s, err: %w", name, out, err)
+	}
 	return nil
 }
 
+// checkFile runs the lexer, parser and checker over file, writing what they find to diags. the error is non nil
+// when any of it is an error. imported is whether another file of the build imports file, see importedFiles
+func checkFile(file string, input string, diags diagnostics.Writer, policy *diagnostics.Policy, imported bool, dbg bool) (*parser.Program, *parser.TypeInfo, error) {
+	l := lexer.New(input)
+	p := parser.New(l)
+	var program *parser.Program
+	err := safeRun(func() {
+		program = p.ParseProgram()
+		if dbg {
+			log.Printf("%s: %s\n", file, program.String())
+		}
+	})
+	if len(p.Errors) != 0 {
+		diags.Write(diagnostics.FromErrors(diagnostics.Error, file, p.Errors))
+		return nil, nil, fmt.Errorf("%s: exiting after parser errrors\n", file)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: recovered parsing: %w\n", file, err)
+	}
+	c := checker.New()
+	c.Imported = imported
+	err = safeRun(func() {
+		c.Check(program)
+	})
+	if err != nil {
+		diags.Write(diagnostics.FromErrors(diagnostics.Error, file, c.Errors))
+		return nil, nil, fmt.Errorf("%s: recovered checking: %w\n", file, err)
+	}
+	checkDiags := policy.Apply(diagnostics.FromErrors(diagnostics.Warning, file, c.Warnings), diagnostics.IgnoresFrom(l.Comments))
+	checkDiags = append(checkDiags, diagnostics.FromErrors(diagnostics.Error, file, c.Errors)...)
+	diags.Write(checkDiags)
+	if diagnostics.HasErrors(checkDiags) {
+		return nil, nil, fmt.Errorf("%s: exiting after checker errors\n", file)
+	}
+	return program, c.Info, nil
+}
+
+// emitFile generates the llvm ir of a checked program, writing the errors only code generation finds to diags. the
+// module is printed in here too, as printing a malformed one panics as well
+func emitFile(file string, program *parser.Program, info *parser.TypeInfo, target emitter.Target, diags diagnostics.Writer) (*emitter.Emitter, string, error) {
+	e := emitter.New(info)
+	e.SetTarget(target)
+	var llvmIr string
+	err := safeRun(func() {
+		e.Emit(program)
+		if len(e.Errors) == 0 {
+			llvmIr = e.Module().String()
+		}
+	})
+	if len(e.Errors) != 0 {
+		diags.Write(diagnostics.FromErrors(diagnostics.Error, file, e.Errors))
+		return nil, "", fmt.Errorf("compiler errors\n")
+	}
+	if err != nil {
+		log.Printf("%s: recovered emitting llvm ir: %s\n", file, err)
+		return nil, "", fmt.Errorf("compiler panic\n")
+	}
+	return e, llvmIr, nil
+}
+
+// importedFiles are the files of the build imported by another of them, keyed by absolute path. each file is checked
+// on its own, so this is how a function or global only used by an importing file isn't reported as unused
+func importedFiles(files []string) map[string]bool {
+	imported := make(map[string]bool)
+	for _, file := range files {
+		input, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var program *parser.Program
+		// errors are reported when the file itself is checked
+		if safeRun(func() { program = parser.New(lexer.New(string(input))).ParseProgram() }) != nil {
+			continue
+		}
+		for _, stmt := range program.Statements {
+			if imp, ok := stmt.(*parser.ImportStatement); ok && strings.HasSuffix(imp.Path, ".gl3") {
+				if path, err := filepath.Abs(imp.Path); err == nil {
+					imported[path] = true
+				}
+			}
+		}
+	}
+	return imported
+}
+
+// isImported looks file up in the result of importedFiles
+func isImported(imported map[string]bool, file string) bool {
+	path, err := filepath.Abs(file)
+	return err == nil && imported[path]
+}
+
 func safeRun(fn func()) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
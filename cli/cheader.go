@@ -0,0 +1,345 @@
+package cli
+
+import (
+	"fmt"
+	"grianlang3/diagnostics"
+	"grianlang3/lexer"
+	"grianlang3/parser"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type CHeaderOpts struct {
+	Output string // path of the header, - for stdout, the first input's name with .h when empty
+	// DiagnosticsFormat is one of diagnostics.Formats
+	DiagnosticsFormat string
+}
+
+// cTypes are the C spellings of gl3's base types, the sized integers coming from stdint.h
+var cTypes = map[lexer.BaseVarType]string{
+	lexer.Int:    "int64_t",
+	lexer.Int32:  "int32_t",
+	lexer.Int16:  "int16_t",
+	lexer.Int8:   "int8_t",
+	lexer.Uint:   "uint64_t",
+	lexer.Uint32: "uint32_t",
+	lexer.Uint16: "uint16_t",
+	lexer.Uint8:  "uint8_t",
+	lexer.Char:   "char",
+	lexer.Bool:   "bool",
+	lexer.Float:  "float",
+	lexer.Void:   "void",
+	lexer.VaList: "va_list",
+}
+
+// RunCHeaderCmd writes a C header describing files to C code linking against them: a prototype for every exported
+// function, the structs those functions work with, and a #define for every global const. the files are checked
+// first, so the header never describes a program that wouldn't build
+func RunCHeaderCmd(files []string, opts *CHeaderOpts) (err error) {
+	policy, err := diagnostics.NewPolicy(nil)
+	if err != nil {
+		return err
+	}
+	diags, err := diagnostics.NewWriter(opts.DiagnosticsFormat)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if flushErr := diags.Flush(); flushErr != nil && err == nil {
+			err = flushErr
+		}
+	}()
+
+	output := opts.Output
+	if output == "" {
+		output = strings.TrimSuffix(filepath.Base(files[0]), ".gl3") + ".h"
+	}
+	guardFrom := output
+	if output == "-" {
+		guardFrom = strings.TrimSuffix(filepath.Base(files[0]), ".gl3") + ".h"
+	}
+
+	var h cHeader
+	imported := importedFiles(files)
+	for _, file := range files {
+		input, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		diags.AddSource(file, string(input))
+		program, _, err := checkFile(file, string(input), diags, policy, isImported(imported, file), false)
+		if err != nil {
+			return err
+		}
+		h.add(file, program)
+	}
+	return writeOutput(output, h.String(files, guardFrom))
+}
+
+type cHeader struct {
+	defines   []string
+	structs   []*parser.StructStatement
+	functions []string
+}
+
+func (h *cHeader) add(file string, program *parser.Program) {
+	for _, stmt := range program.Statements {
+		switch stmt := stmt.(type) {
+		case *parser.StructStatement:
+			h.structs = append(h.structs, stmt)
+		case *parser.FunctionStatement:
+			if !stmt.Exported {
+				continue
+			}
+			proto, ok := cPrototype(stmt)
+			if !ok {
+				// llvm passes first class aggregates field by field, which isn't how the C abi passes structs, so C
+				// calling such a function would read its arguments and result from the wrong places
+				log.Printf("%s: skipping %s, C can't pass structs, optionals, results or multiple values the way gl3 does, use pointers instead\n", file, stmt.Name.Value)
+				h.functions = append(h.functions, fmt.Sprintf("/* %s is exported but can't be called from C */", stmt.Name.Value))
+				continue
+			}
+			h.functions = append(h.functions, proto+";")
+		case *parser.DefStatement:
+			if !stmt.Global || !stmt.Constant || stmt.Extern {
+				continue
+			}
+			value, ok := cConstant(stmt.Type, stmt.Right)
+			if !ok {
+				log.Printf("%s: skipping global const %s, only literals of base types become #defines\n", file, stmt.Name.Value)
+				continue
+			}
+			h.defines = append(h.defines, fmt.Sprintf("#define %s %s", stmt.Name.Value, value))
+		}
+	}
+}
+
+func (h *cHeader) String(files []string, output string) string {
+	guard := strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return '_'
+		}
+		return unicode.ToUpper(r)
+	}, filepath.Base(output))
+	if guard[0] >= '0' && guard[0] <= '9' {
+		guard = "_" + guard
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "/* generated by gl3 cheader from %s, do not edit */\n", strings.Join(files, ", "))
+	fmt.Fprintf(&out, "#ifndef %s\n#define %s\n\n", guard, guard)
+	out.WriteString("#include <stdarg.h>\n#include <stdbool.h>\n#include <stdint.h>\n\n")
+	out.WriteString("#ifdef __cplusplus\nextern \"C\" {\n#endif\n\n")
+
+	if len(h.defines) != 0 {
+		out.WriteString(strings.Join(h.defines, "\n"))
+		out.WriteString("\n\n")
+	}
+
+	if len(h.structs) != 0 {
+		for _, st := range h.structs {
+			fmt.Fprintf(&out, "typedef struct %s %s;\n", st.Name, st.Name)
+		}
+		out.WriteString("\n")
+		for _, st := range sortStructs(h.structs) {
+			out.WriteString(cStruct(st))
+			out.WriteString("\n")
+		}
+	}
+
+	if len(h.functions) != 0 {
+		out.WriteString(strings.Join(h.functions, "\n"))
+		out.WriteString("\n\n")
+	}
+
+	out.WriteString("#ifdef __cplusplus\n}\n#endif\n\n")
+	fmt.Fprintf(&out, "#endif /* %s */\n", guard)
+	return out.String()
+}
+
+// sortStructs orders structs so that every struct comes after those it holds by value, as C needs a struct to be
+// complete before it can be a field. structs only pointed to are covered by the typedefs forward declaring them all
+func sortStructs(structs []*parser.StructStatement) []*parser.StructStatement {
+	byName := make(map[string]*parser.StructStatement, len(structs))
+	for _, st := range structs {
+		byName[st.Name] = st
+	}
+	sorted := make([]*parser.StructStatement, 0, len(structs))
+	visited := make(map[string]bool, len(structs))
+	var visit func(st *parser.StructStatement)
+	visit = func(st *parser.StructStatement) {
+		if visited[st.Name] {
+			return
+		}
+		visited[st.Name] = true
+		for _, field := range st.Types {
+			for _, vt := range []lexer.VarType{field.Unwrapped(), derefErr(field)} {
+				if dep, ok := byName[vt.StructName]; ok && vt.IsStructType && vt.Pointer == 0 {
+					visit(dep)
+				}
+			}
+		}
+		sorted = append(sorted, st)
+	}
+	for _, st := range structs {
+		visit(st)
+	}
+	return sorted
+}
+
+func derefErr(vt lexer.VarType) lexer.VarType {
+	if vt.ErrType == nil {
+		return lexer.VarType{}
+	}
+	return *vt.ErrType
+}
+
+// cStruct is the C definition of a gl3 struct. C lays out fields the way llvm does for the emitted struct type, in
+// order with each aligned to its size, so the two agree on where every field is
+func cStruct(st *parser.StructStatement) string {
+	names := make([]string, len(st.Types))
+	for name, idx := range st.Names {
+		names[idx] = name
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "struct %s {\n", st.Name)
+	for i, field := range st.Types {
+		fmt.Fprintf(&out, "    %s;\n", cField(field, names[i]))
+	}
+	out.WriteString("};\n")
+	return out.String()
+}
+
+// cField declares a struct field. optionals and results are emitted as { i1, T } and { i1, T, E }, which an anonymous
+// struct of a bool and the values matches
+func cField(vt lexer.VarType, name string) string {
+	if !vt.IsWrapped() {
+		return cType(vt) + " " + name
+	}
+	var out strings.Builder
+	out.WriteString("struct { bool ok; ")
+	out.WriteString(cField(vt.Unwrapped(), "value"))
+	out.WriteString("; ")
+	if vt.ErrType != nil {
+		out.WriteString(cField(*vt.ErrType, "err"))
+		out.WriteString("; ")
+	}
+	out.WriteString("} ")
+	out.WriteString(name)
+	return out.String()
+}
+
+func cType(vt lexer.VarType) string {
+	var base string
+	if vt.IsStructType {
+		base = vt.StructName
+	} else {
+		base = cTypes[vt.Base]
+	}
+	return base + strings.Repeat("*", int(vt.Pointer))
+}
+
+// cPrototype is the C prototype of fs, not ok if its parameters or return type are values C can't pass the same way
+func cPrototype(fs *parser.FunctionStatement) (string, bool) {
+	if !cPassable(fs.Type) {
+		return "", false
+	}
+	params := make([]string, 0, len(fs.Params)+1)
+	for _, param := range fs.Params {
+		if !cPassable(param.Type) {
+			return "", false
+		}
+		params = append(params, cType(param.Type)+" "+param.Name.Value)
+	}
+	if fs.Variadic {
+		params = append(params, "...")
+	}
+	if len(params) == 0 {
+		params = append(params, "void")
+	}
+	return fmt.Sprintf("%s %s(%s)", cType(fs.Type), fs.Name.Value, strings.Join(params, ", ")), true
+}
+
+func cPassable(vt lexer.VarType) bool {
+	if vt.Tuple != nil || vt.IsWrapped() {
+		return false
+	}
+	return !vt.IsStructType || vt.Pointer > 0
+}
+
+// cConstant is the C expression for the value of a global const, cast to its type so C sees the same type gl3 does
+func cConstant(vt lexer.VarType, value parser.Expression) (string, bool) {
+	negate := false
+	if prefix, ok := value.(*parser.PrefixExpression); ok && prefix.Operator == "-" {
+		negate = true
+		value = prefix.Right
+	}
+	var literal string
+	switch value := value.(type) {
+	case *parser.IntegerLiteral:
+		switch value.Type.Base {
+		case lexer.Uint, lexer.Uint32, lexer.Uint16, lexer.Uint8:
+			literal = strconv.FormatUint(value.UValue, 10)
+		default:
+			literal = strconv.FormatInt(value.Value, 10)
+		}
+	case *parser.FloatLiteral:
+		literal = strconv.FormatFloat(float64(value.Value), 'g', -1, 32)
+		if !strings.ContainsAny(literal, ".en") {
+			literal += ".0"
+		}
+		literal += "f"
+		if negate {
+			literal = "-" + literal
+		}
+		return literal, true
+	case *parser.BooleanExpression:
+		if negate {
+			return "", false
+		}
+		return strconv.FormatBool(value.Value), true
+	case *parser.StringLiteral:
+		if negate {
+			return "", false
+		}
+		// the lexer null terminates string literals, C does that itself
+		return cString(strings.TrimSuffix(value.Value, "\x00")), true
+	default:
+		return "", false
+	}
+	if negate {
+		literal = "-" + literal
+	}
+	if vt.Pointer > 0 || vt.IsStructType || vt.IsWrapped() || vt.Tuple != nil {
+		return "", false
+	}
+	return fmt.Sprintf("((%s)%s)", cType(vt), literal), true
+}
+
+// cString quotes s as a C string literal, using octal escapes where C and go escapes differ
+func cString(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		case c == '\n':
+			out.WriteString(`\n`)
+		case c == '\t':
+			out.WriteString(`\t`)
+		case c < ' ' || c >= 0x7f:
+			fmt.Fprintf(&out, `\%03o`, c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
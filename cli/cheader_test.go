@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"grianlang3/lexer"
+	"grianlang3/parser"
+	"testing"
+)
+
+func TestCHeaderDeclarations(t *testing.T) {
+	// output is the C cheader writes for the declaration, empty when it's left out of the header
+	tests := map[string]InputOutput{
+		"function":                    {"export fnc add(int32 a, int b) -> uint8 {\n return 0u8\n}", "uint8_t add(int32_t a, int64_t b)"},
+		"no parameters":               {"export fnc tick() -> none {\n}", "void tick(void)"},
+		"variadic":                    {"export fnc log(char* fmt, ...) -> none {\n}", "void log(char* fmt, ...)"},
+		"struct pointer":              {"export fnc move(P** p) -> bool {\n return true\n}", "bool move(P** p)"},
+		"struct parameter":            {"export fnc move(P p) -> none {\n}", ""},
+		"optional return":             {"export fnc find() -> ?int32 {\n return null\n}", ""},
+		"tuple return":                {"export fnc two() -> (int, int) {\n return 1, 2\n}", ""},
+		"struct":                      {"struct P {\n int32 x\n P* next\n}", "struct P {\n    int32_t x;\n    P* next;\n};\n"},
+		"struct with an optional":     {"struct P {\n ?float f\n}", "struct P {\n    struct { bool ok; float value; } f;\n};\n"},
+		"struct with a result":        {"struct P {\n int32!char* r\n}", "struct P {\n    struct { bool ok; int32_t value; char* err; } r;\n};\n"},
+		"int constant":                {"global const int32 x = -5i32", "((int32_t)-5)"},
+		"uint constant":               {"global const uint x = 18446744073709551615u64", "((uint64_t)18446744073709551615)"},
+		"float constant":              {"global const float x = 2.0", "2.0f"},
+		"negative float constant":     {"global const float x = -0.5", "-0.5f"},
+		"bool constant":               {"global const bool x = true", "true"},
+		"string constant":             {"global const char* x = \"a\\\"b\\n\"", "\"a\\\"b\\n\""},
+		"optional constant":           {"global const ?int x = some(1)", ""},
+		"constant of another global":  {"global const int x = y", ""},
+		"negated non-number constant": {"global const bool x = -true", ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := parser.New(lexer.New(test.input))
+			program := p.ParseProgram()
+			if len(p.Errors) != 0 || len(program.Statements) != 1 {
+				t.Fatalf("bad input, parser errors: %v", p.Errors)
+			}
+			got := ""
+			switch stmt := program.Statements[0].(type) {
+			case *parser.FunctionStatement:
+				got, _ = cPrototype(stmt)
+			case *parser.StructStatement:
+				got = cStruct(stmt)
+			case *parser.DefStatement:
+				got, _ = cConstant(stmt.Type, stmt.Right)
+			}
+			if got != test.output {
+				t.Errorf("wanted: %q, got: %q", test.output, got)
+			}
+		})
+	}
+}
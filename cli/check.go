@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"fmt"
+	"grianlang3/diagnostics"
+	"grianlang3/emitter"
+	"os"
+)
+
+type CheckOpts struct {
+	// DiagnosticsFormat is one of diagnostics.Formats
+	DiagnosticsFormat string
+	Warnings          []string // -W flag values, see diagnostics.NewPolicy
+	Codegen           bool     // also generate llvm ir in memory, for the errors only the emitter finds
+}
+
+// RunCheckCmd reports the diagnostics of every file without building anything or writing to the filesystem. unlike
+// build it carries on past a file with errors, so every file's problems are reported in one go
+func RunCheckCmd(files []string, opts *CheckOpts) (err error) {
+	policy, err := diagnostics.NewPolicy(opts.Warnings)
+	if err != nil {
+		return err
+	}
+	diags, err := diagnostics.NewWriter(opts.DiagnosticsFormat)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if flushErr := diags.Flush(); flushErr != nil && err == nil {
+			err = flushErr
+		}
+	}()
+
+	failed := 0
+	imported := importedFiles(files)
+	for _, file := range files {
+		input, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		diags.AddSource(file, string(input))
+
+		program, info, err := checkFile(file, string(input), diags, policy, isImported(imported, file), false)
+		if err == nil && opts.Codegen {
+			_, _, err = emitFile(file, program, info, emitter.HostTarget, diags)
+		}
+		if err != nil {
+			failed++
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d of %d files have errors", failed, len(files))
+	}
+	return nil
+}
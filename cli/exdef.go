@@ -1,25 +1,69 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"grianlang3/lexer"
 	"grianlang3/util"
+	"log"
+	"math"
 	"os"
 	"os/exec"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 )
 
 type ExDefOpts struct {
-	OutFile string
-	InFile  string
+	OutFile     string   // path of the .gl3 file, - for stdout
+	InFiles     []string // headers, read in order as if each included the next
+	IncludeDirs []string // passed to clang as -I
+	Defines     []string // passed to clang as -D
+	Prefixes    []string // when given, only defines starting with one of them are output
+	Match       string   // when given, only defines matching this regular expression are output
+	Force       bool     // overwrite OutFile if it exists
 }
 
 var castRegexp = regexp.MustCompile(`\([a-zA-Z_\* ]+\)`)
 
 func RunExDef(opts *ExDefOpts) error {
-	out, err := exec.Command("clang", "-dM", "-E", opts.InFile).CombinedOutput()
+	if len(opts.InFiles) == 0 {
+		return fmt.Errorf("no input headers given")
+	}
+	var match *regexp.Regexp
+	if opts.Match != "" {
+		var err error
+		match, err = regexp.Compile(opts.Match)
+		if err != nil {
+			return fmt.Errorf("invalid --match: %w", err)
+		}
+	}
+	if opts.OutFile != "-" && !opts.Force {
+		if _, err := os.Stat(opts.OutFile); err == nil {
+			return fmt.Errorf("file %s already exists, use --force to overwrite it", opts.OutFile)
+		}
+	}
+
+	args := []string{"-dM", "-E"}
+	for _, dir := range opts.IncludeDirs {
+		args = append(args, "-I"+dir)
+	}
+	for _, def := range opts.Defines {
+		args = append(args, "-D"+def)
+	}
+	// every header but the last is included ahead of it, so one run sees them all in order
+	last := len(opts.InFiles) - 1
+	for _, header := range opts.InFiles[:last] {
+		args = append(args, "-include", header)
+	}
+	args = append(args, opts.InFiles[last])
+	out, err := exec.Command("clang", args...).Output()
 	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("clang failed: %s\n%s", err, exitErr.Stderr)
+		}
 		return err
 	}
 	defines := util.NewOrderedMap[string, string]()
@@ -38,45 +82,57 @@ func RunExDef(opts *ExDefOpts) error {
 		}
 		defines.Set(name, rest)
 	}
-	var gl3Out strings.Builder
-
-	defines.Range(func(n, v string) {
-		resolveDefine(n, defines)
-	})
 
-	var rangeErr error
+	// every define is kept for resolving the wanted ones, which can refer to any of them
+	var wanted []string
 	defines.Range(func(n, v string) {
 		// linux/unix are compiler headers and shouldn't be included, need to look for similar ones in other OS
 		if n == "linux" || n == "unix" {
 			return
 		}
-
-		// NOTE: doing it this way rather than stripping at readtime because in the future id like to type based off of casts or alternatively special case for example void ptr cast to 0 as a nullptr
-		castStripped := stripCasts(v)
-		_, err := fmt.Fprintf(&gl3Out, "global const %s %s = %s\n", getTypeString(inferType(castStripped)), n, castStripped)
-		if err != nil {
-			rangeErr = err
+		if len(opts.Prefixes) != 0 && !slices.ContainsFunc(opts.Prefixes, func(prefix string) bool { return strings.HasPrefix(n, prefix) }) {
 			return
 		}
+		if match != nil && !match.MatchString(n) {
+			return
+		}
+		wanted = append(wanted, n)
 	})
-	if rangeErr != nil {
-		return rangeErr
-	}
 
-	_, err = os.Stat(opts.OutFile)
-	if err == nil {
-		return fmt.Errorf("File %s aready exists", opts.OutFile)
+	// typed before resolving, which strips the casts the types come from
+	typed := make(map[string]macroValue)
+	for _, n := range wanted {
+		v, _ := defines.Get(n)
+		if mv, ok := typedConstant(v, defines, 0); ok {
+			typed[n] = mv
+		}
 	}
 
-	file, err := os.Create(opts.OutFile)
-	if err != nil {
-		return err
-	}
-	_, err = file.WriteString(gl3Out.String())
-	if err != nil {
-		return err
+	var gl3Out strings.Builder
+	for _, n := range wanted {
+		if mv, ok := typed[n]; ok {
+			lit, ok := mv.literal()
+			if !ok {
+				v, _ := defines.Get(n)
+				log.Printf("skipping %s, %q has no gl3 %s literal\n", n, v, getTypeString(mv.Type))
+				continue
+			}
+			fmt.Fprintf(&gl3Out, "global const %s %s = %s\n", getTypeString(mv.Type), n, lit)
+			continue
+		}
+		// anything more than a single constant, like arithmetic, is typed by a guess at what it looks like
+		resolveDefine(n, defines, 0)
+		v, _ := defines.Get(n)
+		castStripped := stripCasts(v)
+		typ := getTypeString(inferType(castStripped))
+		if typ == "" {
+			log.Printf("skipping %s, %q isn't a constant\n", n, v)
+			continue
+		}
+		fmt.Fprintf(&gl3Out, "global const %s %s = %s\n", typ, n, castStripped)
 	}
-	return nil
+
+	return writeOutput(opts.OutFile, gl3Out.String())
 }
 
 func stripCasts(val string) string {
@@ -90,8 +146,9 @@ func stripCasts(val string) string {
 }
 
 // this will resolve f.e #define A B or #define A "whatever" or #define A (OTHER / 5000)
-func resolveDefine(name string, defines *util.OrderedMap[string, string]) {
+func resolveDefine(name string, defines *util.OrderedMap[string, string], depth int) {
 	def, _ := defines.Get(name)
+	prev := def
 	parts := strings.Fields(def)
 	var newV strings.Builder
 
@@ -106,9 +163,10 @@ func resolveDefine(name string, defines *util.OrderedMap[string, string]) {
 	defines.Set(name, strings.ReplaceAll(strings.TrimSpace(newV.String()), `""`, ``))
 
 	def, _ = defines.Get(name)
-	// if naive infer is string or some bullshit its prob busted but otherwise we can assume its a math op etc
-	if inferType(def).Base == lexer.None {
-		resolveDefine(name, defines)
+	// if naive infer is string or some bullshit its prob busted but otherwise we can assume its a math op etc. stops
+	// once a pass changes nothing, as things like keywords never resolve
+	if inferType(def).Base == lexer.None && def != prev && depth < 32 {
+		resolveDefine(name, defines, depth+1)
 	}
 }
 
@@ -155,19 +213,342 @@ func inferType(val string) lexer.VarType {
 	return lexer.VarType{Base: lexer.Int}
 }
 
+// gl3Types are how gl3 source spells the base types
+var gl3Types = map[lexer.BaseVarType]string{
+	lexer.Int:    "int",
+	lexer.Int32:  "int32",
+	lexer.Int16:  "int16",
+	lexer.Int8:   "int8",
+	lexer.Uint:   "uint",
+	lexer.Uint32: "uint32",
+	lexer.Uint16: "uint16",
+	lexer.Uint8:  "uint8",
+	lexer.Char:   "char",
+	lexer.Bool:   "bool",
+	lexer.Float:  "float",
+	lexer.Void:   "none",
+	lexer.VaList: "va_list",
+}
+
+// literalSuffixes are the suffixes giving integer literals each integer type
+var literalSuffixes = map[lexer.BaseVarType]string{
+	lexer.Int:    "",
+	lexer.Int32:  "i32",
+	lexer.Int16:  "i16",
+	lexer.Int8:   "i8",
+	lexer.Uint:   "u64",
+	lexer.Uint32: "u32",
+	lexer.Uint16: "u16",
+	lexer.Uint8:  "u8",
+}
+
+// getTypeString spells typ as gl3 source, or returns "" if it has no type
 func getTypeString(typ lexer.VarType) string {
-	switch typ.Base {
-	case lexer.Int:
-		return "int"
-	case lexer.Float:
-		return "float"
-	case lexer.Char:
-		if typ.Pointer == 1 {
-			return "char*"
-		} else {
-			return "char"
+	base := typ.StructName
+	if !typ.IsStructType {
+		base = gl3Types[typ.Base]
+	}
+	if base == "" {
+		return ""
+	}
+	return base + strings.Repeat("*", int(typ.Pointer))
+}
+
+// cFixedTypes are the integer types of stdint.h and friends that casts in defines use, on top of C's builtin types
+var cFixedTypes = map[string]lexer.BaseVarType{
+	"int8_t":    lexer.Int8,
+	"int16_t":   lexer.Int16,
+	"int32_t":   lexer.Int32,
+	"int64_t":   lexer.Int,
+	"uint8_t":   lexer.Uint8,
+	"uint16_t":  lexer.Uint16,
+	"uint32_t":  lexer.Uint32,
+	"uint64_t":  lexer.Uint,
+	"size_t":    lexer.Uint,
+	"ssize_t":   lexer.Int,
+	"intptr_t":  lexer.Int,
+	"uintptr_t": lexer.Uint,
+	"ptrdiff_t": lexer.Int,
+}
+
+// typeBits are the sizes of the integer types, in bits
+var typeBits = map[lexer.BaseVarType]uint{
+	lexer.Int8: 8, lexer.Uint8: 8, lexer.Char: 8, lexer.Int16: 16, lexer.Uint16: 16,
+	lexer.Int32: 32, lexer.Uint32: 32, lexer.Int: 64, lexer.Uint: 64,
+}
+
+func isUnsigned(base lexer.BaseVarType) bool {
+	return base == lexer.Uint8 || base == lexer.Uint16 || base == lexer.Uint32 || base == lexer.Uint
+}
+
+// macroValue is the value of a define that's a single constant
+type macroValue struct {
+	Type  lexer.VarType
+	Bits  uint64 // integer, char and bool values, two's complement for negative ones
+	Float float64
+	Str   string // string values as written in C, quotes included
+}
+
+// typedConstant works out the type and value of a define that's a single constant: an integer, float, char or string
+// literal, possibly negated, parenthesized or cast, or the name of another define that is one
+func typedConstant(val string, defines *util.OrderedMap[string, string], depth int) (macroValue, bool) {
+	val = strings.TrimSpace(val)
+	if val == "" || depth > 32 {
+		return macroValue{}, false
+	}
+
+	if val[0] == '(' {
+		end := closingParen(val)
+		if end == len(val)-1 {
+			return typedConstant(val[1:end], defines, depth+1)
+		}
+		if end > 0 {
+			if base, ok := castType(val[1:end]); ok {
+				mv, ok := typedConstant(val[end+1:], defines, depth+1)
+				if !ok {
+					return macroValue{}, false
+				}
+				return mv.convert(base)
+			}
+		}
+		return macroValue{}, false
+	}
+
+	if rest, ok := strings.CutPrefix(val, "-"); ok {
+		mv, ok := typedConstant(rest, defines, depth+1)
+		if !ok {
+			return macroValue{}, false
 		}
+		return mv.negate()
 	}
 
-	return ""
+	if def, ok := defines.Get(val); ok {
+		return typedConstant(def, defines, depth+1)
+	}
+
+	switch {
+	case val[0] == '"':
+		if len(val) < 2 || val[len(val)-1] != '"' || strings.Count(val, "\"") != 2+strings.Count(val, `\"`) {
+			return macroValue{}, false
+		}
+		return macroValue{Type: lexer.VarType{Base: lexer.Char, Pointer: 1}, Str: val}, true
+	case val[0] == '\'':
+		c, ok := parseCChar(val)
+		return macroValue{Type: lexer.VarType{Base: lexer.Char}, Bits: uint64(c)}, ok
+	case util.IsDigit(val[0]) || val[0] == '.':
+		return parseCNumber(val)
+	}
+	return macroValue{}, false
+}
+
+// closingParen is the index of the parenthesis closing the one val starts with, or -1
+func closingParen(val string) int {
+	depth := 0
+	for i := 0; i < len(val); i++ {
+		switch val[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// castType is the gl3 type of the C type in a cast, if it's one gl3 has a literal for
+func castType(typ string) (lexer.BaseVarType, bool) {
+	typ = strings.Join(strings.Fields(strings.ReplaceAll(typ, "const", "")), " ")
+	if base, ok := cFixedTypes[typ]; ok {
+		return base, true
+	}
+	base, ok := cBaseTypes[typ]
+	if !ok || base == lexer.Void || base == lexer.VaList {
+		return 0, false
+	}
+	return base, true
+}
+
+// parseCNumber reads a C integer or float literal. integers take the first type of their suffix's list that fits
+// their value, as in C: decimal ones without a u are only ever signed, while hex, octal and binary ones can also be
+// unsigned types of the same size
+func parseCNumber(val string) (macroValue, bool) {
+	lower := strings.ToLower(val)
+	isHex := strings.HasPrefix(lower, "0x")
+	if !isHex && strings.ContainsAny(lower, ".e") {
+		f, err := strconv.ParseFloat(strings.TrimRight(lower, "fl"), 64)
+		return macroValue{Type: lexer.VarType{Base: lexer.Float}, Float: f}, err == nil
+	}
+
+	digits := strings.TrimRight(lower, "ul")
+	suffix := lower[len(digits):]
+	unsigned := strings.Count(suffix, "u")
+	longs := strings.Count(suffix, "l")
+	if unsigned > 1 || longs > 2 {
+		return macroValue{}, false
+	}
+	base := 10
+	switch {
+	case isHex:
+		base, digits = 16, digits[2:]
+	case strings.HasPrefix(digits, "0b"):
+		base, digits = 2, digits[2:]
+	case len(digits) > 1 && digits[0] == '0':
+		base, digits = 8, digits[1:]
+	}
+	bits, err := strconv.ParseUint(digits, base, 64)
+	if err != nil {
+		return macroValue{}, false
+	}
+
+	var candidates []lexer.BaseVarType
+	switch {
+	case unsigned == 1 && longs == 0:
+		candidates = []lexer.BaseVarType{lexer.Uint32, lexer.Uint}
+	case unsigned == 1:
+		candidates = []lexer.BaseVarType{lexer.Uint}
+	case longs == 0 && base == 10:
+		candidates = []lexer.BaseVarType{lexer.Int32, lexer.Int}
+	case longs == 0:
+		candidates = []lexer.BaseVarType{lexer.Int32, lexer.Uint32, lexer.Int, lexer.Uint}
+	case base == 10:
+		candidates = []lexer.BaseVarType{lexer.Int}
+	default:
+		candidates = []lexer.BaseVarType{lexer.Int, lexer.Uint}
+	}
+	for _, candidate := range candidates {
+		max := uint64(1)<<typeBits[candidate] - 1
+		if !isUnsigned(candidate) {
+			max >>= 1
+		}
+		if bits <= max {
+			return macroValue{Type: lexer.VarType{Base: candidate}, Bits: bits}, true
+		}
+	}
+	return macroValue{}, false
+}
+
+// cEscapes are the single character escapes of C char literals
+var cEscapes = map[byte]byte{
+	'n': '\n', 't': '\t', 'r': '\r', '0': 0, '\\': '\\', '\'': '\'', '"': '"', '?': '?',
+	'a': '\a', 'b': '\b', 'f': '\f', 'v': '\v', 'e': 0x1b,
+}
+
+// parseCChar reads a C char literal like 'a', '\n', '\x1b' or '\033'
+func parseCChar(val string) (byte, bool) {
+	if len(val) < 3 || val[len(val)-1] != '\'' {
+		return 0, false
+	}
+	inner := val[1 : len(val)-1]
+	if len(inner) == 1 {
+		return inner[0], inner[0] != '\\'
+	}
+	if inner[0] != '\\' {
+		return 0, false
+	}
+	if c, ok := cEscapes[inner[1]]; ok && len(inner) == 2 {
+		return c, true
+	}
+	base, digits := 8, inner[1:]
+	if inner[1] == 'x' {
+		base, digits = 16, inner[2:]
+	}
+	c, err := strconv.ParseUint(digits, base, 8)
+	return byte(c), err == nil
+}
+
+// convert casts mv to base the way C does, truncating integers and floats to the size of the new type
+func (mv macroValue) convert(base lexer.BaseVarType) (macroValue, bool) {
+	if mv.Type.Pointer != 0 {
+		return macroValue{}, false
+	}
+	to := macroValue{Type: lexer.VarType{Base: base}}
+	switch {
+	case base == lexer.Float && mv.Type.Base == lexer.Float:
+		to.Float = mv.Float
+	case base == lexer.Float:
+		to.Float = float64(mv.signed())
+		if isUnsigned(mv.Type.Base) {
+			to.Float = float64(mv.Bits)
+		}
+	case base == lexer.Bool && mv.Type.Base == lexer.Float:
+		to.Bits = boolBits(mv.Float != 0)
+	case base == lexer.Bool:
+		to.Bits = boolBits(mv.Bits != 0)
+	case mv.Type.Base == lexer.Float:
+		to.Bits = uint64(int64(mv.Float))
+		if mv.Float >= 1<<63 {
+			to.Bits = uint64(mv.Float)
+		}
+	default:
+		to.Bits = mv.Bits
+	}
+	if size, ok := typeBits[base]; ok && size < 64 {
+		to.Bits &= 1<<size - 1
+	}
+	return to, true
+}
+
+func boolBits(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// negate is -mv, unsigned values wrapping around as in C
+func (mv macroValue) negate() (macroValue, bool) {
+	switch {
+	case mv.Type.Pointer != 0 || mv.Type.Base == lexer.Bool:
+		return macroValue{}, false
+	case mv.Type.Base == lexer.Float:
+		mv.Float = -mv.Float
+	default:
+		mv.Bits = -mv.Bits
+		if size := typeBits[mv.Type.Base]; size < 64 {
+			mv.Bits &= 1<<size - 1
+		}
+	}
+	return mv, true
+}
+
+// signed is the value of an integer mv, sign extended from the size of its type
+func (mv macroValue) signed() int64 {
+	size := typeBits[mv.Type.Base]
+	return int64(mv.Bits<<(64-size)) >> (64 - size)
+}
+
+// literal spells mv as a gl3 literal of its type, or is false for the values gl3 has no literal for: floats outside
+// float32's range, and the smallest int, as its negation is parsed before the minus and overflows
+func (mv macroValue) literal() (string, bool) {
+	switch {
+	case mv.Type.Pointer != 0:
+		return mv.Str, true
+	case mv.Type.Base == lexer.Float:
+		f := float32(mv.Float)
+		if math.IsInf(float64(f), 0) || math.IsNaN(float64(f)) {
+			return "", false
+		}
+		lit := strconv.FormatFloat(float64(f), 'f', -1, 32)
+		if !strings.Contains(lit, ".") {
+			lit += ".0"
+		}
+		return lit, true
+	case mv.Type.Base == lexer.Bool:
+		return strconv.FormatBool(mv.Bits != 0), true
+	case mv.Type.Base == lexer.Char:
+		// gl3 char literals have no escapes, the rest are written as the int8s chars are interchangeable with
+		if mv.Bits >= ' ' && mv.Bits <= '~' && mv.Bits != '\'' && mv.Bits != '\\' {
+			return "'" + string(rune(mv.Bits)) + "'", true
+		}
+		return strconv.FormatInt(mv.signed(), 10) + "i8", true
+	case isUnsigned(mv.Type.Base):
+		return strconv.FormatUint(mv.Bits, 10) + literalSuffixes[mv.Type.Base], true
+	case mv.Type.Base == lexer.Int && mv.signed() == math.MinInt64:
+		return "", false
+	default:
+		return strconv.FormatInt(mv.signed(), 10) + literalSuffixes[mv.Type.Base], true
+	}
 }
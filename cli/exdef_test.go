@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"grianlang3/util"
+	"testing"
+)
+
+type InputOutput struct {
+	input  string
+	output string
+}
+
+func TestTypedConstant(t *testing.T) {
+	defines := util.NewOrderedMap[string, string]()
+	defines.Set("BASE", "0x10u")
+	defines.Set("ALIAS", "BASE")
+	defines.Set("LOOP", "LOOP")
+	defines.Set("SUM", "1 + 2")
+
+	// output is the type and literal exdef writes for the define, empty when it skips it
+	tests := map[string]InputOutput{
+		"int":                         {"42", "int32 42i32"},
+		"negative int":                {"-42", "int32 -42i32"},
+		"parenthesized":               {"((7))", "int32 7i32"},
+		"negated in parens":           {"(-(7))", "int32 -7i32"},
+		"unsigned":                    {"7u", "uint32 7u32"},
+		"negative unsigned wraps":     {"-1u", "uint32 4294967295u32"},
+		"cast":                        {"((uint8_t)300)", "uint8 44u8"},
+		"cast to builtin type":        {"(unsigned long)1", "uint 1u64"},
+		"cast with const":             {"(const short)-1", "int16 -1i16"},
+		"negative cast":               {"(int8_t)-1", "int8 -1i8"},
+		"cast float to int":           {"(int)2.9", "int32 2i32"},
+		"cast int to float":           {"(float)3", "float 3.0"},
+		"cast to bool":                {"(_Bool)5", "bool true"},
+		"float":                       {"1.5", "float 1.5"},
+		"negative float":              {"-0.25f", "float -0.25"},
+		"float past float32":          {"1e40", ""},
+		"negative float past float32": {"-1e40", ""},
+		"char":                        {"'a'", "char 'a'"},
+		"escaped char":                {"'\\n'", "char 10i8"},
+		"quote char":                  {"'\\''", "char 39i8"},
+		"string":                      {"\"hi\"", "char* \"hi\""},
+		"string with escaped quote":   {"\"a\\\"b\"", "char* \"a\\\"b\""},
+		"unterminated string":         {"\"hi", ""},
+		"two strings":                 {"\"a\" \"b\"", ""},
+		"define":                      {"BASE", "uint32 16u32"},
+		"define of a define":          {"(ALIAS)", "uint32 16u32"},
+		"cast define":                 {"(int64_t)ALIAS", "int 16"},
+		"recursive define":            {"LOOP", ""},
+		"arithmetic define":           {"SUM", ""},
+		"arithmetic":                  {"1 << 4", ""},
+		"cast to pointer":             {"((void *)0)", ""},
+		"negated string":              {"-\"a\"", ""},
+		"smallest int":                {"((int64_t)0x8000000000000000)", ""},
+		"smallest int32":              {"(-2147483647 - 1)", ""},
+		"negated smallest int32":      {"-2147483648", "int -2147483648"},
+		"empty":                       {"", ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := ""
+			if mv, ok := typedConstant(test.input, defines, 0); ok {
+				if lit, ok := mv.literal(); ok {
+					got = getTypeString(mv.Type) + " " + lit
+				}
+			}
+			if got != test.output {
+				t.Errorf("wanted: %q, got: %q", test.output, got)
+			}
+		})
+	}
+}
+
+func TestParseCNumber(t *testing.T) {
+	// output is the type and value parseCNumber reads, empty when it rejects the literal
+	tests := map[string]InputOutput{
+		"decimal":                     {"10", "int32 10i32"},
+		"zero":                        {"0", "int32 0i32"},
+		"decimal past int32":          {"2147483648", "int 2147483648"},
+		"decimal past int":            {"9223372036854775808", ""},
+		"hex":                         {"0xff", "int32 255i32"},
+		"upper case hex":              {"0XFF", "int32 255i32"},
+		"hex past int32":              {"0x80000000", "uint32 2147483648u32"},
+		"hex past uint32":             {"0x100000000", "int 4294967296"},
+		"hex past int":                {"0x8000000000000000", "uint 9223372036854775808u64"},
+		"hex past uint":               {"0x10000000000000000", ""},
+		"octal":                       {"017", "int32 15i32"},
+		"binary":                      {"0b101", "int32 5i32"},
+		"unsigned":                    {"5u", "uint32 5u32"},
+		"unsigned past uint32":        {"4294967296u", "uint 4294967296u64"},
+		"long":                        {"5l", "int 5"},
+		"long long":                   {"5LL", "int 5"},
+		"hex long past int":           {"0x8000000000000000l", "uint 9223372036854775808u64"},
+		"decimal long past int":       {"9223372036854775808l", ""},
+		"unsigned long":               {"5ul", "uint 5u64"},
+		"long unsigned":               {"5lu", "uint 5u64"},
+		"unsigned long long":          {"5ULL", "uint 5u64"},
+		"two u suffixes":              {"5uu", ""},
+		"three l suffixes":            {"5lll", ""},
+		"float":                       {"2.5", "float 2.5"},
+		"float without leading digit": {".5", "float 0.5"},
+		"float suffix":                {"2.5f", "float 2.5"},
+		"long double suffix":          {"2.5L", "float 2.5"},
+		"exponent":                    {"1e3", "float 1000.0"},
+		"negative exponent":           {"25e-2", "float 0.25"},
+		"hex with e is an integer":    {"0xe", "int32 14i32"},
+		"bad octal digit":             {"09", ""},
+		"bad digits":                  {"12ab", ""},
+		"bad float":                   {"1.2.3", ""},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := ""
+			if mv, ok := parseCNumber(test.input); ok {
+				lit, _ := mv.literal()
+				got = getTypeString(mv.Type) + " " + lit
+			}
+			if got != test.output {
+				t.Errorf("wanted: %q, got: %q", test.output, got)
+			}
+		})
+	}
+}
+
+func TestParseCChar(t *testing.T) {
+	tests := map[string]struct {
+		input  string
+		output byte
+		ok     bool
+	}{
+		"plain":              {"'a'", 'a', true},
+		"space":              {"' '", ' ', true},
+		"newline":            {"'\\n'", '\n', true},
+		"nul":                {"'\\0'", 0, true},
+		"backslash":          {"'\\\\'", '\\', true},
+		"quote":              {"'\\''", '\'', true},
+		"escape":             {"'\\e'", 0x1b, true},
+		"hex":                {"'\\x1b'", 0x1b, true},
+		"upper case hex":     {"'\\xFF'", 0xff, true},
+		"octal":              {"'\\033'", 0x1b, true},
+		"largest octal":      {"'\\377'", 0xff, true},
+		"octal past a byte":  {"'\\400'", 0, false},
+		"hex past a byte":    {"'\\x100'", 0, false},
+		"hex without digits": {"'\\x'", 0, false},
+		"unknown escape":     {"'\\q'", 0, false},
+		"lone backslash":     {"'\\'", 0, false},
+		"multi char":         {"'ab'", 0, false},
+		"empty":              {"''", 0, false},
+		"unterminated":       {"'a", 0, false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, ok := parseCChar(test.input)
+			if ok != test.ok || (ok && c != test.output) {
+				t.Errorf("wanted: %q %v, got: %q %v", test.output, test.ok, c, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+	"grianlang3/diagnostics"
+	"io"
+	"strings"
+)
+
+// RunExplainCmd prints the explanation of the diagnostic code in args, or lists every code when there's none
+func RunExplainCmd(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		for _, exp := range diagnostics.Explanations() {
+			title := exp.Title
+			if exp.Name != "" {
+				title += " (-W" + exp.Name + ")"
+			}
+			if _, err := fmt.Fprintf(w, "%s  %s\n", exp.Code, title); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	exp, ok := diagnostics.Explain(args[0])
+	if !ok {
+		return fmt.Errorf("unknown diagnostic code %s, run gl3 explain to list them", args[0])
+	}
+	var out strings.Builder
+	fmt.Fprintf(&out, "%s: %s\n\n%s\n", exp.Code, exp.Title, exp.Text)
+	if exp.Name != "" {
+		fmt.Fprintf(&out, "\nThis warning is named %s, -Wno-%s turns it off and -Werror=%s makes it an error.\n", exp.Name, exp.Name, exp.Name)
+	}
+	if exp.Example != "" {
+		out.WriteString("\nFor example:\n\n")
+		for l := range strings.SplitSeq(exp.Example, "\n") {
+			if l != "" {
+				out.WriteString("    " + l)
+			}
+			out.WriteString("\n")
+		}
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"grianlang3/diagnostics"
+	"grianlang3/emitter"
+	"slices"
+	"testing"
+)
+
+// codeWriter collects the codes of the diagnostics written to it
+type codeWriter struct {
+	codes []string
+}
+
+func (w *codeWriter) AddSource(string, string) { /* noop */ }
+func (w *codeWriter) Flush() error             { return nil }
+func (w *codeWriter) Write(diags []diagnostics.Diagnostic) {
+	for _, d := range diags {
+		w.codes = append(w.codes, d.Code)
+	}
+}
+
+func TestExplainExamples(t *testing.T) {
+	policy, err := diagnostics.NewPolicy(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, exp := range diagnostics.Explanations() {
+		if exp.Example == "" {
+			continue
+		}
+		t.Run(exp.Code, func(t *testing.T) {
+			target := emitter.HostTarget
+			if exp.Code == diagnostics.CodeUnsupportedTarget {
+				target, _ = emitter.LookupTarget("aarch64-unknown-linux-gnu")
+			}
+			w := &codeWriter{}
+			program, info, err := checkFile(exp.Code+".gl3", exp.Example, w, policy, false, false)
+			if err == nil {
+				_, _, _ = emitFile(exp.Code+".gl3", program, info, target, w)
+			}
+			// the example shows exactly the problem it explains
+			if want := []string{exp.Code}; !slices.Equal(w.codes, want) {
+				t.Errorf("wanted: %v, got: %v", want, w.codes)
+			}
+		})
+	}
+}
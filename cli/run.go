@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// ExitError is returned when a program run by gl3 run exits unsuccessfully, so gl3 can exit with the same code
+type ExitError struct {
+	Code int
+}
+
+func (ee *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", ee.Code)
+}
+
+// RunRunCmd builds files into a temporary directory and runs the executable with args, connected to gl3's own stdin,
+// stdout and stderr. the executable is removed once it exits
+func RunRunCmd(builtinFs embed.FS, files []string, args []string, opts *BuildOpts) error {
+	dir, err := os.MkdirTemp("", "gl3-run-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	opts.Output = filepath.Join(dir, "out")
+	opts.Emit = "exe"
+	opts.NoExecBuild = false
+	if err := RunBuildCmd(builtinFs, files, opts); err != nil {
+		return err
+	}
+
+	cmd := exec.Command(opts.Output, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitCode()
+		// killed by a signal
+		if code < 0 {
+			code = 1
+		}
+		return &ExitError{Code: code}
+	}
+	return err
+}
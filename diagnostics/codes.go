@@ -0,0 +1,495 @@
+package diagnostics
+
+import (
+	"slices"
+	"strings"
+)
+
+// codes are stable, E for errors and W for warnings, and are never reused once a kind of problem stops being reported
+const (
+	CodeSyntax            = "E0001"
+	CodeBadLiteral        = "E0002"
+	CodeBadType           = "E0003"
+	CodeBadDeclaration    = "E0004"
+	CodeBadAssignTarget   = "E0005"
+	CodeBadCallTarget     = "E0006"
+	CodeUndefinedVariable = "E0007"
+	CodeUndefinedFunction = "E0008"
+	CodeUndefinedStruct   = "E0009"
+	CodeUnknownField      = "E0010"
+	CodeRedefinition      = "E0011"
+	CodeTypeMismatch      = "E0012"
+	CodeBadOperator       = "E0013"
+	CodeBadCast           = "E0014"
+	CodeBadAddress        = "E0015"
+	CodeArgumentCount     = "E0016"
+	CodeNotUnwrapped      = "E0017"
+	CodeBadUnwrap         = "E0018"
+	CodeBadWrap           = "E0019"
+	CodeNotDestructured   = "E0020"
+	CodeMissingReturn     = "E0021"
+	CodeOutsideLoop       = "E0022"
+	CodeAssignToConst     = "E0023"
+	CodeImportNotFound    = "E0024"
+	CodeModuleNotImported = "E0025"
+	CodeBadIntrinsic      = "E0026"
+	CodeBadFormat         = "E0027"
+	CodeNonConstant       = "E0028"
+	CodeInternal          = "E0029"
+	CodeUnsupportedTarget = "E0030"
+	CodeUnreachable       = "W0001"
+	CodeUnused            = "W0002"
+	CodeUnusedImport      = "W0003"
+	CodePointerTruncation = "W0004"
+)
+
+// Explanation is the long form of a code, printed by gl3 explain
+type Explanation struct {
+	Code    string
+	Name    string // what -W and -Wno- call a warning, empty for errors
+	Title   string
+	Text    string
+	Example string // source showing the problem and, in comments, how to fix it
+}
+
+// Explain returns the explanation for code, which is matched case insensitively
+func Explain(code string) (Explanation, bool) {
+	exp, ok := explanations[strings.ToUpper(code)]
+	return exp, ok
+}
+
+// Explanations returns every explanation, ordered by code
+func Explanations() []Explanation {
+	exps := make([]Explanation, 0, len(explanations))
+	for _, exp := range explanations {
+		exps = append(exps, exp)
+	}
+	slices.SortFunc(exps, func(a, b Explanation) int {
+		return strings.Compare(a.Code, b.Code)
+	})
+	return exps
+}
+
+var explanations = make(map[string]Explanation)
+
+func init() {
+	for _, exp := range []Explanation{
+		{
+			Code:  CodeSyntax,
+			Title: "syntax error",
+			Text: `The parser found a token it didn't expect where it was, like a missing closing brace or an operator with
+nothing on one side. Parsing resumes at the next statement, so a single mistake can hide others in the same
+statement but not in the ones after it.`,
+			Example: `fnc main() -> int32 {
+    def int32 x = 1i32 +    // nothing after +
+    return x
+}`,
+		},
+		{
+			Code:  CodeBadLiteral,
+			Title: "invalid number literal",
+			Text: `A number literal isn't a valid number, or is too large to be represented in 64 bits. Integer literals are
+parsed as 64 bit values before their suffix narrows them.`,
+			Example: `def uint x = 99999999999999999999u64    // larger than the largest uint`,
+		},
+		{
+			Code:  CodeBadType,
+			Title: "invalid type",
+			Text: `A type is written in a form the language doesn't allow. Optionals and results can't wrap another optional or
+result, and a return type list, written in parentheses, needs at least two types since a single return value is
+written as a bare type.`,
+			Example: `fnc find(int32 id) -> ??int32 {    // use ?int32
+    return null
+}`,
+		},
+		{
+			Code:  CodeBadDeclaration,
+			Title: "invalid declaration",
+			Text: `A declaration uses a form only allowed elsewhere: const is only for globals, globals can't be destructured,
+and the variadic ... has to be the last parameter of a function.`,
+			Example: `fnc log(..., char* fmt) -> none {    // write fnc log(char* fmt, ...)
+}`,
+		},
+		{
+			Code:  CodeBadAssignTarget,
+			Title: "invalid assignment target",
+			Text: `Only variables, dereferenced pointers and struct fields can be assigned to. The left hand side of = was
+something else, like a call or a literal.`,
+			Example: `get_count() = 5i32    // assign to a variable or through a pointer instead`,
+		},
+		{
+			Code:  CodeBadCallTarget,
+			Title: "invalid call target",
+			Text: `Functions are called by name, there are no function values, so only an identifier can be followed by an
+argument list.`,
+			Example: `struct Counter {
+    int32 n
+}
+
+fnc main() -> int32 {
+    def Counter c = Counter:{ 0i32 }
+    return c.n()    // there are no methods, write c.n
+}`,
+		},
+		{
+			Code:  CodeUndefinedVariable,
+			Title: "undefined variable",
+			Text: `A name was used that isn't a local, parameter or global in scope at that point. Locals are only visible in
+the block that defines them and blocks nested inside it, after their def.`,
+			Example: `fnc main() -> int32 {
+    if true {
+        def int32 x = 1i32
+        return x
+    }
+    return x    // x went out of scope with the if block
+}`,
+		},
+		{
+			Code:  CodeUndefinedFunction,
+			Title: "undefined function",
+			Text: `A function was called that isn't defined in this file, declared with extern fnc, or provided by an imported
+module. Functions can be called before the point they are defined.`,
+			Example: `fnc main() -> int32 {
+    return compute()    // define fnc compute() -> int32, or extern fnc it
+}`,
+		},
+		{
+			Code:  CodeUndefinedStruct,
+			Title: "undefined struct",
+			Text: `A struct type was used that has no struct definition, or whose definition comes later in the file.
+Structs have to be defined before their name is used, except in pointer fields of the struct itself.`,
+			Example: `fnc main() -> int32 {
+    return (sizeof Point) as int32    // define struct Point { int32 x int32 y } before using it
+}`,
+		},
+		{
+			Code:  CodeUnknownField,
+			Title: "unknown struct field",
+			Text: `The dot operator was used with a field the struct doesn't have, with something other than a field name on
+its right, or on a value that isn't a struct.`,
+			Example: `struct Point {
+    int32 x
+    int32 y
+}
+
+fnc main() -> int32 {
+    def Point p = Point:{ 1i32, 2i32 }
+    return p.z    // Point only has x and y
+}`,
+		},
+		{
+			Code:  CodeRedefinition,
+			Title: "redefinition",
+			Text: `A name was defined twice in the same scope: two locals in one block, two parameters of one function, two
+globals or two functions. An inner block may shadow a name from an outer one. The diagnostic points at the first
+definition too.`,
+			Example: `fnc main() -> int32 {
+    def int32 x = 1i32
+    def int32 x = x + 1i32    // rename one, or assign with x = x + 1i32
+    return x
+}`,
+		},
+		{
+			Code:  CodeTypeMismatch,
+			Title: "mismatched types",
+			Text: `A value doesn't have the type expected of it where it's used. There are no implicit conversions, so every
+value has to have exactly the expected type, the only exception being char and int8 which can be used in place of
+each other. Convert values with as, or give literals the right suffix.`,
+			Example: `fnc main() -> int32 {
+    def int32 x = 10    // 10 is an int, write 10i32
+    return x
+}`,
+		},
+		{
+			Code:  CodeBadOperator,
+			Title: "invalid operator for type",
+			Text: `An operator was used on operand types it isn't defined for, like adding a bool, or its operands have
+different types. Both sides of a binary operator need the same type.`,
+			Example: `fnc main() -> int32 {
+    return 1i32 + 2    // 1i32 is int32 but 2 is int, write 2i32
+}`,
+		},
+		{
+			Code:  CodeBadCast,
+			Title: "invalid cast",
+			Text: `as converts between numbers, pointers and bools. Structs, optionals and results can't be cast, cast a
+pointer to a struct instead.`,
+			Example: `struct Point {
+    int32 x
+    int32 y
+}
+
+fnc main() -> int32 {
+    def Point p = Point:{ 1i32, 2i32 }
+    return p as int32    // cast one of its fields instead, like p.x
+}`,
+		},
+		{
+			Code:  CodeBadAddress,
+			Title: "invalid address or dereference",
+			Text: `& takes the address of a local variable and nothing else, and * dereferences a pointer. Globals,
+parameters and temporary values can't have their address taken, copy them into a local first.`,
+			Example: `fnc inc(int32 n) -> int32 {
+    def int32* p = &n    // n is a parameter, use def int32 m = n then &m
+    return *p + 1i32
+}
+
+fnc main() -> int32 {
+    return inc(1i32)
+}`,
+		},
+		{
+			Code:  CodeArgumentCount,
+			Title: "wrong number of arguments",
+			Text: `A function or builtin was called with more or fewer arguments than it takes, or a struct initialization
+gave a different number of values than the struct has fields. Variadic functions take at least their fixed
+parameters.`,
+			Example: `fnc add(int32 a, int32 b) -> int32 {
+    return a + b
+}
+
+fnc main() -> int32 {
+    return add(1i32)    // add takes 2 arguments
+}`,
+		},
+		{
+			Code:  CodeNotUnwrapped,
+			Title: "optional or result used without unwrapping",
+			Text: `An optional or result was used where its value is expected. Unwrap it with try or ? to return early when
+there's no value, or with orelse to fall back to another value.`,
+			Example: `fnc find(int32 id) -> ?int32 {
+    if id > 0i32 {
+        return some(id)
+    }
+    return null
+}
+
+fnc main() -> int32 {
+    return find(1i32) + 1i32    // write (find(1i32) orelse 0i32) + 1i32
+}`,
+		},
+		{
+			Code:  CodeBadUnwrap,
+			Title: "invalid try or orelse",
+			Text: `try and orelse only unwrap optionals and results. try also returns early from the enclosing function, which
+therefore has to return an optional, or a result with the same error type as the unwrapped result, so that the
+missing value or error can be passed along.`,
+			Example: `fnc parse(char* s) -> int32!char* {
+    return err(s)
+}
+
+fnc main() -> int32 {
+    return try parse("5")    // main returns int32, use parse("5") orelse 0i32
+}`,
+		},
+		{
+			Code:  CodeBadWrap,
+			Title: "invalid null, some, ok or err",
+			Text: `null and some make optionals, ok and err make results, so they can only be used where an optional or a
+result is expected. null, ok and err take their type from that context, so they can't be used where there is none,
+like an operand of an operator.`,
+			Example: `fnc main() -> int32 {
+    return null    // main returns int32, not ?int32
+}`,
+		},
+		{
+			Code:  CodeNotDestructured,
+			Title: "multiple values not destructured",
+			Text: `A function returning several values has to have its result destructured into as many variables, and
+return statements in it have to return as many values as its return type list has types.`,
+			Example: `fnc divmod(int32 a, int32 b) -> (int32, int32) {
+    return a / b, a - a / b * b
+}
+
+fnc main() -> int32 {
+    def int32 q = divmod(7i32, 2i32)    // write def int32 q, int32 r = divmod(7i32, 2i32)
+    return q
+}`,
+		},
+		{
+			Code:  CodeMissingReturn,
+			Title: "missing return",
+			Text: `A function that returns a value can reach the end of its body without a return, or has a bare return.
+Every path through the body has to end in a return with a value, only functions returning none get an implicit
+one. A while true loop without a break never reaches the end of the body.`,
+			Example: `fnc sign(int x) -> int32 {
+    if x < 0 {
+        return -1i32
+    }
+    // add return 1i32 for when x >= 0
+}
+
+fnc main() -> int32 {
+    return sign(5)
+}`,
+		},
+		{
+			Code:  CodeOutsideLoop,
+			Title: "statement outside of its construct",
+			Text:  `break and continue can only be used inside a while loop, and defer only inside a function body.`,
+			Example: `fnc main() -> int32 {
+    if true {
+        break    // there's no loop to break out of
+    }
+    return 0i32
+}`,
+		},
+		{
+			Code:  CodeAssignToConst,
+			Title: "assignment to constant or parameter",
+			Text: `Globals defined with const can't be assigned to, and neither can parameters. Copy a parameter into a local
+variable to change it.`,
+			Example: `fnc count_down(int32 n) -> none {
+    while n > 0i32 {
+        n = n - 1i32    // use def int32 i = n and change i instead
+    }
+}
+
+fnc main() -> int32 {
+    count_down(3i32)
+    return 0i32
+}`,
+		},
+		{
+			Code:  CodeImportNotFound,
+			Title: "import not found",
+			Text: `An import names a standard library module that doesn't exist, or a .gl3 file that couldn't be found.
+Paths to .gl3 files are relative to the directory the compiler is run in.`,
+			Example: `import "strings.gl3"    // no such file`,
+		},
+		{
+			Code:  CodeModuleNotImported,
+			Title: "standard library module not imported",
+			Text: `A standard library function, or syntax that uses one like array literals, was used without importing the
+module that provides it.`,
+			Example: `// add import "arrays"
+fnc main() -> int32 {
+    def int32* nums = [int32; 1i32, 2i32]
+    return nums[0]
+}`,
+		},
+		{
+			Code:  CodeBadIntrinsic,
+			Title: "invalid use of a builtin",
+			Text: `A builtin such as va_start, va_arg, va_end or __asm__salloc was used in a way it doesn't support. The va_
+builtins only work in variadic functions, va_arg takes a va_list and a sizeof expression giving the type to read,
+and __asm__salloc takes an integer count and a sizeof expression.`,
+			Example: `fnc first(int32 n, ...) -> int32 {
+    def va_list args = va_start()
+    def int32 x = va_arg(args, 4)    // give the type to read with sizeof, va_arg(args, sizeof int32)
+    va_end(args)
+    return n + x
+}
+
+fnc main() -> int32 {
+    return first(1i32, 2i32)
+}`,
+		},
+		{
+			Code:  CodeBadFormat,
+			Title: "invalid print format",
+			Text: `The format string of print or println is checked against its arguments: it has to be a string literal, have
+one specifier per argument, and each specifier has to match its argument's type, like %d for int32 and %s for
+char*.`,
+			Example: `import "io"
+
+fnc main() -> int32 {
+    println("%d and %d", 1i32)    // two specifiers but one argument
+    return 0i32
+}`,
+		},
+		{
+			Code:  CodeNonConstant,
+			Title: "non constant initializer",
+			Text: `Globals are initialized before the program runs, so their initial value, and the fields of a struct they're
+initialized with, have to be constants rather than calls or references to other variables.`,
+			Example: `fnc compute_limit() -> int32 {
+    return 10i32
+}
+
+global int32 limit = compute_limit()    // use a literal, and assign the result in main
+
+fnc main() -> int32 {
+    return limit
+}`,
+		},
+		{
+			Code:  CodeInternal,
+			Title: "internal compiler error",
+			Text: `Code generation ran into something the type checker should have rejected or resolved. This is a bug in the
+compiler, please report it along with the source that triggers it.`,
+		},
+		{
+			Code:  CodeUnsupportedTarget,
+			Title: "not supported on the target",
+			Text: `The program uses something gl3 can't generate code for on the target it's built for. Defining variadic
+functions isn't supported on aarch64 outside of Apple and Windows platforms, where va_list splits the extra arguments
+between saved registers and the stack. Calling C variadic functions like printf works on every target.`,
+			Example: `fnc first(int32 n, ...) -> int32 {    // with --target aarch64-unknown-linux-gnu, take a pointer and a count
+    def va_list args = va_start()
+    def int32 x = va_arg(args, sizeof int32)
+    va_end(args)
+    return n + x
+}
+
+fnc main() -> int32 {
+    return first(1i32, 2i32)
+}`,
+		},
+		{
+			Code:  CodeUnreachable,
+			Name:  "unreachable",
+			Title: "unreachable code",
+			Text: `A statement comes after a return, break or continue, or an if whose branches all leave the block, so it can
+never run. Only the first such statement in a block is reported.`,
+			Example: `import "io"
+
+fnc main() -> int32 {
+    return 0i32
+    println("done")    // never runs
+}`,
+		},
+		{
+			Code:  CodeUnused,
+			Name:  "unused",
+			Title: "unused declaration",
+			Text: `A local, parameter, global or function is never referenced. Assigning to a variable counts as a use. Prefix
+a name with _ to silence the warning, main, extern and export declarations are never reported.`,
+			Example: `import "io"
+
+fnc on_event(int32 code, char* msg) -> none {    // rename code to _code
+    println("%s", msg)
+}
+
+fnc main() -> int32 {
+    on_event(1i32, "started")
+    return 0i32
+}`,
+		},
+		{
+			Code:  CodeUnusedImport,
+			Name:  "unused-import",
+			Title: "unused import",
+			Text:  `None of the functions or globals an import provides are used, so it can be removed.`,
+			Example: `import "strings"    // nothing from strings is used
+
+fnc main() -> int32 {
+    return 0i32
+}`,
+		},
+		{
+			Code:  CodePointerTruncation,
+			Name:  "pointer-truncation",
+			Title: "pointer cast may truncate",
+			Text: `A pointer was cast to an integer type narrower than a pointer, which drops its upper bits. Cast pointers to
+int or uint to keep them whole.`,
+			Example: `fnc main() -> int32 {
+    def int32 x = 1i32
+    def int32 addr = (&x) as int32    // write (&x) as int
+    return addr
+}`,
+		},
+	} {
+		explanations[exp.Code] = exp
+	}
+}
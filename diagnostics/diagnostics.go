@@ -0,0 +1,60 @@
+package diagnostics
+
+import "grianlang3/util"
+
+// Severity is how serious a diagnostic is, only errors stop a build
+type Severity uint8
+
+const (
+	Error Severity = iota + 1
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	default:
+		return "unknown"
+	}
+}
+
+// Label is a secondary location shown alongside a diagnostic's own, like where a redefined name was first defined
+type Label struct {
+	Position *util.Position
+	Msg      string
+}
+
+// Diagnostic is a message about a source file, from any stage of the compiler
+type Diagnostic struct {
+	Severity Severity
+	Code     string // stable identifier of the kind of problem, empty for those without one
+	File     string
+	Position *util.Position // nil when there's nothing in the source to point at
+	Msg      string
+	Labels   []Label
+}
+
+// FromErrors converts the position errors a compiler stage collected into diagnostics for file
+func FromErrors(severity Severity, file string, errs []util.PositionError) []Diagnostic {
+	diags := make([]Diagnostic, 0, len(errs))
+	for _, err := range errs {
+		d := Diagnostic{
+			Severity: severity,
+			Code:     err.Code,
+			File:     file,
+			Position: err.Position,
+			Msg:      err.Msg,
+		}
+		for _, related := range err.Related {
+			d.Labels = append(d.Labels, Label{Position: related.Position, Msg: related.Msg})
+		}
+		diags = append(diags, d)
+	}
+	return diags
+}
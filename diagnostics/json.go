@@ -0,0 +1,55 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSONWriter collects diagnostics and writes them as one JSON array on Flush
+type JSONWriter struct {
+	w     io.Writer
+	diags []jsonDiagnostic
+}
+
+type jsonDiagnostic struct {
+	File     string      `json:"file"`
+	Severity string      `json:"severity"`
+	Code     string      `json:"code,omitempty"`
+	Message  string      `json:"message"`
+	Range    *Range      `json:"range,omitempty"`
+	Related  []jsonLabel `json:"related,omitempty"`
+}
+
+type jsonLabel struct {
+	Message string `json:"message"`
+	Range   *Range `json:"range,omitempty"`
+}
+
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{w: w, diags: []jsonDiagnostic{}}
+}
+
+// AddSource is a noop, the output only refers to files by name
+func (jw *JSONWriter) AddSource(file string, src string) {}
+
+func (jw *JSONWriter) Write(diags []Diagnostic) {
+	for _, d := range diags {
+		jd := jsonDiagnostic{
+			File:     d.File,
+			Severity: d.Severity.String(),
+			Code:     d.Code,
+			Message:  d.Msg,
+			Range:    RangeOf(d.Position),
+		}
+		for _, label := range d.Labels {
+			jd.Related = append(jd.Related, jsonLabel{Message: label.Msg, Range: RangeOf(label.Position)})
+		}
+		jw.diags = append(jw.diags, jd)
+	}
+}
+
+func (jw *JSONWriter) Flush() error {
+	enc := json.NewEncoder(jw.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jw.diags)
+}
@@ -0,0 +1,139 @@
+package diagnostics
+
+import (
+	"fmt"
+	"grianlang3/lexer"
+	"strings"
+)
+
+// Policy decides what becomes of warnings: whether they're reported at all, and whether they fail the build like
+// errors do. every warning is reported, as a warning, unless flags say otherwise
+type Policy struct {
+	allErrors bool
+	disabled  map[string]struct{}
+	errors    map[string]struct{}
+}
+
+// NewPolicy builds a policy from the values of -W flags, applied in order so later ones win:
+//
+//	error          report every warning as an error
+//	error=<name>   report the named warning as an error
+//	<name>         report the named warning
+//	no-<name>      don't report the named warning
+//
+// names are those of Explanation.Name, or the warning codes themselves
+func NewPolicy(flags []string) (*Policy, error) {
+	p := &Policy{disabled: make(map[string]struct{}), errors: make(map[string]struct{})}
+	for _, flag := range flags {
+		if flag == "error" {
+			p.allErrors = true
+			continue
+		}
+		name, isError := strings.CutPrefix(flag, "error=")
+		name, disable := strings.CutPrefix(name, "no-")
+		code, ok := warningCode(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown warning %q in -W%s, run gl3 explain to list them", name, flag)
+		}
+		switch {
+		case isError && disable:
+			return nil, fmt.Errorf("-W%s can't both disable a warning and make it an error", flag)
+		case isError:
+			p.errors[code] = struct{}{}
+			delete(p.disabled, code)
+		case disable:
+			p.disabled[code] = struct{}{}
+		default:
+			delete(p.disabled, code)
+		}
+	}
+	return p, nil
+}
+
+func warningCode(name string) (string, bool) {
+	if exp, ok := Explain(name); ok && exp.Name != "" {
+		return exp.Code, true
+	}
+	for _, exp := range explanations {
+		if exp.Name != "" && exp.Name == name {
+			return exp.Code, true
+		}
+	}
+	return "", false
+}
+
+// Apply drops the warnings in diags that are disabled or ignored in source, and turns those that should fail the
+// build into errors. errors are always kept as they are
+func (p *Policy) Apply(diags []Diagnostic, ignores *Ignores) []Diagnostic {
+	kept := make([]Diagnostic, 0, len(diags))
+	for _, d := range diags {
+		if d.Severity == Warning {
+			if _, ok := p.disabled[d.Code]; ok || ignores.Ignored(d) {
+				continue
+			}
+			if _, ok := p.errors[d.Code]; ok || p.allErrors {
+				d.Severity = Error
+			}
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
+
+// HasErrors reports whether any of diags is an error
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Ignores are the warnings a file silences with gl3:ignore comments. `// gl3:ignore <code>...` silences the codes on
+// its own line and the next, so it can trail the offending line or sit above it, and `// gl3:ignore-file <code>...`
+// silences them in the whole file. warnings can be given by code or by name
+type Ignores struct {
+	file  map[string]struct{}
+	lines map[uint32]map[string]struct{}
+}
+
+// IgnoresFrom reads the gl3:ignore directives out of a file's comments
+func IgnoresFrom(comments []lexer.Comment) *Ignores {
+	ig := &Ignores{file: make(map[string]struct{}), lines: make(map[uint32]map[string]struct{})}
+	for _, comment := range comments {
+		directive, args, _ := strings.Cut(strings.TrimSpace(comment.Text), " ")
+		for _, code := range strings.FieldsFunc(args, func(r rune) bool { return r == ' ' || r == ',' || r == '\t' }) {
+			if named, ok := warningCode(code); ok {
+				code = named
+			}
+			switch directive {
+			case "gl3:ignore":
+				for _, line := range []uint32{comment.Position.StartLine, comment.Position.StartLine + 1} {
+					if ig.lines[line] == nil {
+						ig.lines[line] = make(map[string]struct{})
+					}
+					ig.lines[line][code] = struct{}{}
+				}
+			case "gl3:ignore-file":
+				ig.file[code] = struct{}{}
+			}
+		}
+	}
+	return ig
+}
+
+// Ignored reports whether d is silenced, only warnings can be
+func (ig *Ignores) Ignored(d Diagnostic) bool {
+	if ig == nil || d.Severity != Warning {
+		return false
+	}
+	if _, ok := ig.file[d.Code]; ok {
+		return true
+	}
+	if !hasLine(d.Position) {
+		return false
+	}
+	_, ok := ig.lines[d.Position.StartLine][d.Code]
+	return ok
+}
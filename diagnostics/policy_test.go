@@ -0,0 +1,71 @@
+package diagnostics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	// output is the severity each of W0001, W0002 and W0003 is reported with, - when it's dropped, or the error
+	// NewPolicy returns
+	tests := map[string]struct {
+		flags  []string
+		output string
+	}{
+		"no flags":                   {nil, "warning warning warning"},
+		"every warning an error":     {[]string{"error"}, "error error error"},
+		"one warning an error":       {[]string{"error=unused"}, "warning error warning"},
+		"disabled":                   {[]string{"no-unused-import"}, "warning warning -"},
+		"disabled by code":           {[]string{"no-W0001"}, "- warning warning"},
+		"code in lower case":         {[]string{"no-w0001"}, "- warning warning"},
+		"re-enabled":                 {[]string{"no-unused", "unused"}, "warning warning warning"},
+		"error re-enables":           {[]string{"no-unused", "error=unused"}, "warning error warning"},
+		"disabled after error":       {[]string{"error=unused", "no-unused"}, "warning - warning"},
+		"disabled with every error":  {[]string{"error", "no-unreachable"}, "- error error"},
+		"unknown warning":            {[]string{"no-shadow"}, `unknown warning "shadow" in -Wno-shadow, run gl3 explain to list them`},
+		"error code":                 {[]string{"E0001"}, `unknown warning "E0001" in -WE0001, run gl3 explain to list them`},
+		"both disabled and an error": {[]string{"error=no-unused"}, "-Werror=no-unused can't both disable a warning and make it an error"},
+	}
+
+	warnings := []Diagnostic{
+		{Severity: Warning, Code: CodeUnreachable},
+		{Severity: Warning, Code: CodeUnused},
+		{Severity: Warning, Code: CodeUnusedImport},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewPolicy(test.flags)
+			if err != nil {
+				if err.Error() != test.output {
+					t.Errorf("wanted: %q, got error: %q", test.output, err)
+				}
+				return
+			}
+			kept := p.Apply(warnings, IgnoresFrom(nil))
+			var got []string
+			for _, w := range warnings {
+				severity := "-"
+				for _, d := range kept {
+					if d.Code == w.Code {
+						severity = d.Severity.String()
+					}
+				}
+				got = append(got, severity)
+			}
+			if strings.Join(got, " ") != test.output {
+				t.Errorf("wanted: %q, got: %q", test.output, strings.Join(got, " "))
+			}
+		})
+	}
+
+	t.Run("errors are kept", func(t *testing.T) {
+		p, err := NewPolicy([]string{"no-unused"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		kept := p.Apply([]Diagnostic{{Severity: Error, Code: CodeSyntax}}, IgnoresFrom(nil))
+		if len(kept) != 1 || kept[0].Severity != Error {
+			t.Errorf("wanted the error kept, got: %v", kept)
+		}
+	})
+}
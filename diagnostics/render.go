@@ -0,0 +1,154 @@
+package diagnostics
+
+import (
+	"fmt"
+	"grianlang3/util"
+	"io"
+	"strconv"
+	"strings"
+
+	"charm.land/lipgloss/v2"
+)
+
+var (
+	severityStyles = map[Severity]lipgloss.Style{
+		Error:   lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Red),
+		Warning: lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Yellow),
+		Note:    lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Cyan),
+	}
+	msgStyle    = lipgloss.NewStyle().Bold(true)
+	gutterStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Blue)
+	labelStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Blue)
+)
+
+// Renderer writes diagnostics as text, quoting the source lines they point at with the offending span underlined.
+// colors are dropped when the writer isn't a terminal
+type Renderer struct {
+	w       io.Writer
+	sources map[string][]string
+}
+
+func NewRenderer(w io.Writer) *Renderer {
+	return &Renderer{w: w, sources: make(map[string][]string)}
+}
+
+// AddSource makes file's source available for quoting, diagnostics for files without it are rendered without snippets
+func (r *Renderer) AddSource(file string, src string) {
+	r.sources[file] = strings.Split(src, "\n")
+}
+
+func (r *Renderer) Render(d Diagnostic) {
+	var out strings.Builder
+	sevStyle := severityStyles[d.Severity]
+	if d.Code != "" {
+		out.WriteString(sevStyle.Render(d.Severity.String() + "[" + d.Code + "]:"))
+	} else {
+		out.WriteString(sevStyle.Render(d.Severity.String() + ":"))
+	}
+	out.WriteString(" ")
+	out.WriteString(msgStyle.Render(d.Msg))
+	out.WriteString("\n")
+
+	lines := r.sources[d.File]
+	width := len(strconv.Itoa(maxLine(d)))
+	indent := strings.Repeat(" ", width)
+	if hasLine(d.Position) {
+		fmt.Fprintf(&out, "%s%s %s:%d:%d\n", indent, gutterStyle.Render("-->"), d.File, d.Position.StartLine, d.Position.StartCol)
+	} else if d.File != "" {
+		fmt.Fprintf(&out, "%s%s %s\n", indent, gutterStyle.Render("-->"), d.File)
+	}
+
+	bar := gutterStyle.Render("|")
+	quoted := false
+	quote := func(pos *util.Position, mark string, style lipgloss.Style, msg string) bool {
+		if !hasLine(pos) || int(pos.StartLine) > len(lines) {
+			return false
+		}
+		if !quoted {
+			fmt.Fprintf(&out, "%s %s\n", indent, bar)
+			quoted = true
+		}
+		line := strings.TrimSuffix(lines[pos.StartLine-1], "\r")
+		num := strconv.Itoa(int(pos.StartLine))
+		fmt.Fprintf(&out, "%s%s %s %s\n", strings.Repeat(" ", width-len(num)), gutterStyle.Render(num), bar, line)
+
+		start, end := underlineSpan(line, pos)
+		underline := style.Render(strings.Repeat(mark, end-start))
+		if msg != "" {
+			underline += " " + style.Render(msg)
+		}
+		fmt.Fprintf(&out, "%s %s %s%s\n", indent, bar, padding(line, start), underline)
+		return true
+	}
+
+	quote(d.Position, "^", sevStyle, "")
+	for _, label := range d.Labels {
+		if quote(label.Position, "-", labelStyle, label.Msg) {
+			continue
+		}
+		// nothing to quote, so say where it is instead
+		fmt.Fprintf(&out, "%s %s %s", indent, gutterStyle.Render("="), label.Msg)
+		if hasLine(label.Position) {
+			fmt.Fprintf(&out, " at %s:%d:%d", d.File, label.Position.StartLine, label.Position.StartCol)
+		}
+		out.WriteString("\n")
+	}
+	out.WriteString("\n")
+
+	lipgloss.Fprint(r.w, out.String())
+}
+
+func (r *Renderer) Write(diags []Diagnostic) {
+	for _, d := range diags {
+		r.Render(d)
+	}
+}
+
+// Flush is a noop, diagnostics are rendered as they're written
+func (r *Renderer) Flush() error {
+	return nil
+}
+
+func hasLine(pos *util.Position) bool {
+	return pos != nil && pos.StartLine > 0
+}
+
+func maxLine(d Diagnostic) int {
+	maxLine := 0
+	if hasLine(d.Position) {
+		maxLine = int(d.Position.StartLine)
+	}
+	for _, label := range d.Labels {
+		if hasLine(label.Position) {
+			maxLine = max(maxLine, int(label.Position.StartLine))
+		}
+	}
+	return maxLine
+}
+
+// underlineSpan is the byte range of line to underline for pos, columns being 1 based with EndCol one past the span.
+// spans running onto later lines are underlined to the end of the first, and every span is at least one wide
+func underlineSpan(line string, pos *util.Position) (int, int) {
+	start := min(max(int(pos.StartCol)-1, 0), len(line))
+	end := len(line)
+	if pos.EndLine == pos.StartLine {
+		end = min(int(pos.EndCol)-1, len(line))
+	}
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
+
+// padding lines an underline up with line[start:], keeping its tabs so they expand to the same width
+func padding(line string, start int) string {
+	var pad strings.Builder
+	for i := 0; i < start && i < len(line); i++ {
+		if line[i] == '\t' {
+			pad.WriteByte('\t')
+		} else {
+			pad.WriteByte(' ')
+		}
+	}
+	return pad.String()
+}
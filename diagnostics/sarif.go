@@ -0,0 +1,120 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// SARIFWriter collects diagnostics and writes them as a SARIF 2.1.0 log with a single run on Flush, the format code
+// scanning tools and CI annotations read
+type SARIFWriter struct {
+	w       io.Writer
+	results []sarifResult
+	rules   []sarifRule
+	ruleIDs map[string]struct{}
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID           string          `json:"ruleId,omitempty"`
+	Level            string          `json:"level"`
+	Message          sarifMessage    `json:"message"`
+	Locations        []sarifLocation `json:"locations,omitempty"`
+	RelatedLocations []sarifLocation `json:"relatedLocations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	ID               *int                  `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *Range                `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+func NewSARIFWriter(w io.Writer) *SARIFWriter {
+	return &SARIFWriter{w: w, results: []sarifResult{}, ruleIDs: make(map[string]struct{})}
+}
+
+// AddSource is a noop, the output only refers to files by name
+func (sw *SARIFWriter) AddSource(file string, src string) {}
+
+func (sw *SARIFWriter) Write(diags []Diagnostic) {
+	for _, d := range diags {
+		result := sarifResult{
+			RuleID:  d.Code,
+			Level:   d.Severity.String(),
+			Message: sarifMessage{Text: d.Msg},
+		}
+		if d.File != "" {
+			result.Locations = []sarifLocation{sarifLocationOf(d.File, RangeOf(d.Position), nil)}
+		}
+		for i, label := range d.Labels {
+			loc := sarifLocationOf(d.File, RangeOf(label.Position), &sarifMessage{Text: label.Msg})
+			loc.ID = &i
+			result.RelatedLocations = append(result.RelatedLocations, loc)
+		}
+		if _, ok := sw.ruleIDs[d.Code]; !ok && d.Code != "" {
+			sw.ruleIDs[d.Code] = struct{}{}
+			sw.rules = append(sw.rules, sarifRule{ID: d.Code})
+		}
+		sw.results = append(sw.results, result)
+	}
+}
+
+func (sw *SARIFWriter) Flush() error {
+	log := sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: "gl3", Rules: sw.rules}},
+			Results: sw.results,
+		}},
+	}
+	enc := json.NewEncoder(sw.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+func sarifLocationOf(file string, region *Range, msg *sarifMessage) sarifLocation {
+	return sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: file},
+			Region:           region,
+		},
+		Message: msg,
+	}
+}
@@ -0,0 +1,54 @@
+package diagnostics
+
+import (
+	"fmt"
+	"grianlang3/util"
+	"os"
+)
+
+// Writer is where a command sends its diagnostics, either rendered as they come or collected into one document for
+// tools once the command is done
+type Writer interface {
+	AddSource(file string, src string)
+	Write(diags []Diagnostic)
+	Flush() error
+}
+
+// Formats are the names NewWriter accepts
+var Formats = []string{"text", "json", "sarif"}
+
+// NewWriter returns the writer for format. text is for people and goes to stderr, json and sarif are for tools and go
+// to stdout so they can be piped without anything else mixed in
+func NewWriter(format string) (Writer, error) {
+	switch format {
+	case "text":
+		return NewRenderer(os.Stderr), nil
+	case "json":
+		return NewJSONWriter(os.Stdout), nil
+	case "sarif":
+		return NewSARIFWriter(os.Stdout), nil
+	}
+	return nil, fmt.Errorf("unknown diagnostics format %q, expected one of %v", format, Formats)
+}
+
+// Range is a normalized span, 1 based with the end column one past the last character. machine readable output uses
+// it rather than util.Position, whose end column is inclusive for single character tokens
+type Range struct {
+	StartLine uint32 `json:"startLine"`
+	StartCol  uint32 `json:"startColumn"`
+	EndLine   uint32 `json:"endLine"`
+	EndCol    uint32 `json:"endColumn"`
+}
+
+// RangeOf returns the normalized span of pos, nil when there's nothing in the source to point at
+func RangeOf(pos *util.Position) *Range {
+	if !hasLine(pos) {
+		return nil
+	}
+	r := &Range{StartLine: pos.StartLine, StartCol: max(pos.StartCol, 1), EndLine: pos.EndLine, EndCol: pos.EndCol}
+	if r.EndLine < r.StartLine || (r.EndLine == r.StartLine && r.EndCol <= r.StartCol) {
+		r.EndLine = r.StartLine
+		r.EndCol = r.StartCol + 1
+	}
+	return r
+}
@@ -7,6 +7,7 @@ import (
 	_ "embed"
 
 	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
 	"github.com/llir/llvm/ir/types"
 )
 
@@ -14,11 +15,45 @@ type BuiltinDef struct {
 	RetType   types.Type
 	RetGlType lexer.VarType
 	Params    []types.Type
-	Variadic  bool
+	// GlParams are the types the checker checks arguments against, AnyPointer taking a pointer of any type
+	GlParams []lexer.VarType
+	Variadic bool
 }
 
-func NewBuiltinDef(ret types.Type, glRet lexer.VarType, variadic bool, param ...types.Type) BuiltinDef {
-	return BuiltinDef{RetType: ret, RetGlType: glRet, Params: param, Variadic: variadic}
+// AnyPointer is the type of builtin parameters that take any pointer, like a void* in C. the argument is bitcast to
+// the i8* the builtin is declared with
+var AnyPointer = lexer.VarType{Base: lexer.Void, Pointer: 1}
+
+func NewBuiltinDef(ret types.Type, glRet lexer.VarType, variadic bool, param ...lexer.VarType) BuiltinDef {
+	def := BuiltinDef{RetType: ret, RetGlType: glRet, GlParams: param, Variadic: variadic}
+	for _, p := range param {
+		def.Params = append(def.Params, builtinParamType(p))
+	}
+	return def
+}
+
+// builtinParamType is the llvm type of a builtin parameter, which only ever has a scalar type or a pointer
+func builtinParamType(vt lexer.VarType) types.Type {
+	if vt.Pointer > 0 {
+		return types.I8Ptr
+	}
+	switch vt.Base {
+	case lexer.Int, lexer.Uint:
+		return types.I64
+	case lexer.Int32, lexer.Uint32:
+		return types.I32
+	case lexer.Int16, lexer.Uint16:
+		return types.I16
+	case lexer.Int8, lexer.Uint8, lexer.Char:
+		return types.I8
+	case lexer.Bool:
+		return types.I1
+	case lexer.Float:
+		return types.Float
+	case lexer.VaList:
+		return types.I8Ptr
+	}
+	panic(fmt.Sprintf("builtin parameter of type %s", vt))
 }
 
 func newVt(base lexer.BaseVarType) lexer.VarType {
@@ -31,42 +66,54 @@ func newVtPtr(base lexer.BaseVarType, ptr uint8) lexer.VarType {
 
 var builtinModules = map[string]map[string]BuiltinDef{
 	"dbg": {
-		"dbg_i64":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, types.I64),
-		"dbg_i32":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, types.I32),
-		"dbg_i16":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, types.I16),
-		"dbg_i8":    NewBuiltinDef(types.Void, newVt(lexer.Void), false, types.I8),
-		"dbg_u64":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, types.I64),
-		"dbg_u32":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, types.I32),
-		"dbg_u16":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, types.I16),
-		"dbg_u8":    NewBuiltinDef(types.Void, newVt(lexer.Void), false, types.I8),
-		"dbg_float": NewBuiltinDef(types.Void, newVt(lexer.Void), false, types.Float),
-		"dbg_bool":  NewBuiltinDef(types.Void, newVt(lexer.Void), false, types.I1),
-		"dbg_str":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, types.I8Ptr),
-		"dbg_char":  NewBuiltinDef(types.Void, newVt(lexer.Void), false, types.I8),
+		"dbg_i64":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, newVt(lexer.Int)),
+		"dbg_i32":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, newVt(lexer.Int32)),
+		"dbg_i16":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, newVt(lexer.Int16)),
+		"dbg_i8":    NewBuiltinDef(types.Void, newVt(lexer.Void), false, newVt(lexer.Int8)),
+		"dbg_u64":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, newVt(lexer.Uint)),
+		"dbg_u32":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, newVt(lexer.Uint32)),
+		"dbg_u16":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, newVt(lexer.Uint16)),
+		"dbg_u8":    NewBuiltinDef(types.Void, newVt(lexer.Void), false, newVt(lexer.Uint8)),
+		"dbg_float": NewBuiltinDef(types.Void, newVt(lexer.Void), false, newVt(lexer.Float)),
+		"dbg_bool":  NewBuiltinDef(types.Void, newVt(lexer.Void), false, newVt(lexer.Bool)),
+		"dbg_str":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, newVtPtr(lexer.Char, 1)),
+		"dbg_char":  NewBuiltinDef(types.Void, newVt(lexer.Void), false, newVt(lexer.Char)),
 	},
 	//"malloc":    NewBuiltinDef(types.I8Ptr, newVtPtr(lexer.Int8, 1), types.I64),
 	"arrays": {
-		"arr_new":  NewBuiltinDef(types.I8Ptr, newVtPtr(lexer.None, 1), false, types.I64),
-		"arr_push": NewBuiltinDef(types.Void, newVt(lexer.None), false, types.I8Ptr, types.I8Ptr),
-		"arr_free": NewBuiltinDef(types.Void, newVt(lexer.None), false, types.I8Ptr),
+		"arr_new":  NewBuiltinDef(types.I8Ptr, newVtPtr(lexer.None, 1), false, newVt(lexer.Uint)),
+		"arr_push": NewBuiltinDef(types.Void, newVt(lexer.None), false, AnyPointer, AnyPointer),
+		"arr_free": NewBuiltinDef(types.Void, newVt(lexer.None), false, AnyPointer),
 	},
 	"strings": {
-		"dynstr":     NewBuiltinDef(types.I8Ptr, newVtPtr(lexer.Int8, 1), false, types.I8Ptr),
-		"str_append": NewBuiltinDef(types.I8Ptr, newVtPtr(lexer.Int8, 1), false, types.I8Ptr, types.I8Ptr),
-		"str_len":    NewBuiltinDef(types.I64, newVt(lexer.Uint), false, types.I8Ptr),
-		"str_free":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, types.I8Ptr),
+		"dynstr":     NewBuiltinDef(types.I8Ptr, newVtPtr(lexer.Int8, 1), false, newVtPtr(lexer.Char, 1)),
+		"str_append": NewBuiltinDef(types.I8Ptr, newVtPtr(lexer.Int8, 1), false, newVtPtr(lexer.Char, 1), newVtPtr(lexer.Char, 1)),
+		"str_len":    NewBuiltinDef(types.I64, newVt(lexer.Uint), false, newVtPtr(lexer.Char, 1)),
+		"str_free":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, newVtPtr(lexer.Char, 1)),
 	},
 	"io": {
-		"print":   NewBuiltinDef(types.Void, newVt(lexer.Void), true, types.I8Ptr),
-		"println": NewBuiltinDef(types.Void, newVt(lexer.Void), true, types.I8Ptr),
+		"print":    NewBuiltinDef(types.Void, newVt(lexer.Void), true, newVtPtr(lexer.Char, 1)),
+		"println":  NewBuiltinDef(types.Void, newVt(lexer.Void), true, newVtPtr(lexer.Char, 1)),
+		"vprint":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, newVtPtr(lexer.Char, 1), newVt(lexer.VaList)),
+		"vprintln": NewBuiltinDef(types.Void, newVt(lexer.Void), false, newVtPtr(lexer.Char, 1), newVt(lexer.VaList)),
 	},
 	"ralloc": {
-		"malloc": NewBuiltinDef(types.I8Ptr, newVtPtr(lexer.Int8, 1), false, types.I64),
-		"calloc": NewBuiltinDef(types.I8Ptr, newVtPtr(lexer.Int8, 1), false, types.I64, types.I64),
-		"free":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, types.I8Ptr),
+		"malloc": NewBuiltinDef(types.I8Ptr, newVtPtr(lexer.Int8, 1), false, newVt(lexer.Uint)),
+		"calloc": NewBuiltinDef(types.I8Ptr, newVtPtr(lexer.Int8, 1), false, newVt(lexer.Uint), newVt(lexer.Uint)),
+		"free":   NewBuiltinDef(types.Void, newVt(lexer.Void), false, AnyPointer),
 	},
 }
 
+// allocatingBuiltins return freshly allocated memory nothing else points to, which their declarations mark noalias so
+// llvm can keep values loaded from other pointers across stores through them
+var allocatingBuiltins = map[string]struct{}{
+	"arr_new":    {},
+	"dynstr":     {},
+	"str_append": {},
+	"malloc":     {},
+	"calloc":     {},
+}
+
 func GetBuiltinNames() map[string]map[string]struct{} {
 	m := make(map[string]map[string]struct{})
 	for builtinModule, names := range builtinModules {
@@ -81,6 +128,12 @@ func GetBuiltinNames() map[string]map[string]struct{} {
 	return m
 }
 
+// GetBuiltinModule returns the functions defined by a builtin module, keyed by name
+func GetBuiltinModule(moduleName string) (map[string]BuiltinDef, bool) {
+	builtins, ok := builtinModules[moduleName]
+	return builtins, ok
+}
+
 func AddBuiltinModule(e *Emitter, moduleName string) error {
 	builtins, ok := builtinModules[moduleName]
 	if !ok {
@@ -94,8 +147,13 @@ func AddBuiltinModule(e *Emitter, moduleName string) error {
 		}
 		fnc := e.m.NewFunc(name, typing.RetType, params...)
 		fnc.Sig.Variadic = typing.Variadic
+		// the builtins are plain C, nothing unwinds through them
+		fnc.FuncAttrs = append(fnc.FuncAttrs, enum.FuncAttrNoUnwind)
+		if _, ok := allocatingBuiltins[name]; ok {
+			fnc.ReturnAttrs = append(fnc.ReturnAttrs, enum.ReturnAttrNoAlias)
+		}
 		e.functions[name] = fnc
-		e.functionGlReturnTypes[name] = typing.RetGlType
+		e.cFunctions[name] = struct{}{}
 	}
 	e.builtinModules = append(e.builtinModules, fmt.Sprintf("%s.ll", moduleName))
 
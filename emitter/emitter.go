@@ -2,9 +2,11 @@ package emitter
 
 import (
 	"fmt"
+	"grianlang3/diagnostics"
 	"grianlang3/lexer"
 	"grianlang3/parser"
 	"grianlang3/util"
+	"math/big"
 	"os"
 	"strings"
 
@@ -20,16 +22,16 @@ type Emitter struct {
 	currBlock *ir.Block
 	currFnc   *ir.Func
 
-	// var, vartypes, params get reset after each function is emitted.
-	variables         map[string]*ir.InstAlloca
-	globals           map[string]*ir.Global
-	varTypes          map[string]types.Type
-	varGlTypes        map[string]lexer.VarType
-	parameters        map[string]*ir.Param
-	parametersGlTypes map[string]lexer.VarType
+	// info is the checker's resolved types and symbols, which are used over working them out again here
+	info *parser.TypeInfo
 
-	functions             map[string]*ir.Func
-	functionGlReturnTypes map[string]lexer.VarType
+	// var, params get reset after each function is emitted.
+	variables  map[*parser.Symbol]*ir.InstAlloca
+	globals    map[string]*ir.Global
+	parameters map[string]*ir.Param
+
+	functions     map[string]*ir.Func
+	currFncGlType lexer.VarType
 
 	stringLiterals map[string]*ir.Global
 
@@ -39,52 +41,74 @@ type Emitter struct {
 	ioModuleImported   bool
 	emittingVarargArgs bool
 	astFuncs           map[string]struct{}
+	vaFuncs            map[string]struct{}
+	currFncVariadic    bool
 
-	structTypes         map[string]*types.StructType
-	structMemberIndexes map[string]map[string]int
-	structMemberTypes   map[string][]lexer.VarType
+	structTypes map[string]*types.StructType
 
 	whileStack []WhileLoopState
+	// deferStack holds one frame of deferred expressions per open block, innermost last
+	deferStack [][]parser.Expression
+
+	// pointerSize is the size of pointers on the target, in bytes
+	pointerSize int64
+	// genericVaArg is whether va_arg can be left to llvm on the target, see Target.GenericVaArg
+	genericVaArg bool
+	// vaListPointer is whether C takes a va_list as a plain pointer on the target, see Target.VaListIsPointer
+	vaListPointer bool
+	// cFunctions are the functions implemented in C, the builtins and extern declarations
+	cFunctions map[string]struct{}
 
 	Errors []util.PositionError
 }
 
 // VariableState used simply for transport when restoring state across if stmt blocks
 type VariableState struct {
-	variables  map[string]*ir.InstAlloca
-	varTypes   map[string]types.Type
-	varGlTypes map[string]lexer.VarType
+	variables map[*parser.Symbol]*ir.InstAlloca
 }
 
 type WhileLoopState struct {
 	condBlock *ir.Block
 	endBlock  *ir.Block
+	// deferDepth is the amount of defer frames open outside the loop, break/continue run everything above it
+	deferDepth int
 }
 
-func New() *Emitter {
-	e := &Emitter{m: ir.NewModule()}
+// New creates an emitter for a program the checker has resolved into info
+func New(info *parser.TypeInfo) *Emitter {
+	e := &Emitter{m: ir.NewModule(), info: info, pointerSize: HostTarget.PointerSize, genericVaArg: HostTarget.GenericVaArg(),
+		vaListPointer: HostTarget.VaListIsPointer()}
 	e.globals = make(map[string]*ir.Global)
-	e.variables = make(map[string]*ir.InstAlloca)
-	e.varTypes = make(map[string]types.Type)
+	e.variables = make(map[*parser.Symbol]*ir.InstAlloca)
 	e.functions = make(map[string]*ir.Func)
+	e.cFunctions = make(map[string]struct{})
 	e.parameters = make(map[string]*ir.Param)
-	e.functionGlReturnTypes = make(map[string]lexer.VarType)
-	e.parametersGlTypes = make(map[string]lexer.VarType)
-	e.varGlTypes = make(map[string]lexer.VarType)
 	e.stringLiterals = make(map[string]*ir.Global)
 	e.asmModuleImported = false
 	e.astFuncs = map[string]struct{}{
 		"__asm__salloc": {},
 	}
+	e.vaFuncs = map[string]struct{}{
+		"va_start": {},
+		"va_arg":   {},
+		"va_end":   {},
+	}
 	e.structTypes = make(map[string]*types.StructType)
-	e.structMemberIndexes = make(map[string]map[string]int)
-	e.structMemberTypes = make(map[string][]lexer.VarType)
 
 	//fnc = e.m.NewFunc("malloc", types.I32Ptr, ir.NewParam("val", types.I64Ptr))
 	//e.functions["malloc"] = fnc
 	return e
 }
 
+// SetTarget makes the module build for target, it has to be called before Emit
+func (e *Emitter) SetTarget(target Target) {
+	e.m.TargetTriple = target.Triple
+	e.m.DataLayout = target.DataLayout
+	e.pointerSize = target.PointerSize
+	e.genericVaArg = target.GenericVaArg()
+	e.vaListPointer = target.VaListIsPointer()
+}
+
 func (e *Emitter) Module() *ir.Module {
 	return e.m
 }
@@ -141,6 +165,10 @@ var glTypeUInts = map[lexer.BaseVarType]struct{}{
 }
 
 func (e *Emitter) Emit(node parser.Node) (value.Value, lexer.VarType) {
+	// expressions take the type the checker resolved for them rather than working it out again
+	if expr, ok := node.(parser.Expression); ok {
+		return e.emitExpression(expr), e.typeOf(expr)
+	}
 	switch node := node.(type) {
 	case *parser.Program:
 		var last value.Value
@@ -152,6 +180,270 @@ func (e *Emitter) Emit(node parser.Node) (value.Value, lexer.VarType) {
 		return last, lastType
 	case *parser.ExpressionStatement:
 		return e.Emit(node.Expression)
+	case *parser.DefStatement:
+		if node.Extern {
+			global := e.declareGlobal(GlobalDeclare{Name: node.Name.Value, Type: node.Type, Constant: node.Constant})
+			return global, node.Type
+		}
+		lt := e.varTypeToLlvm(node.Type)
+		right, vt := e.Emit(node.Right)
+		if vt.Tuple != nil {
+			e.appendError(node.Position(), diagnostics.CodeNotDestructured, "multiple values of type %s must be destructured, i.e def int a, int b = f()", vt)
+			return nil, lexer.VarType{}
+		}
+
+		if node.Global {
+			vPtr := e.m.NewGlobal(node.Name.Value, lt)
+
+			init, ok := right.(constant.Constant)
+			if !ok {
+				e.appendError(node.Position(), diagnostics.CodeNonConstant, "global variable must be initialized with a constant value")
+				return nil, lexer.VarType{}
+			}
+			vPtr.Init = init
+			vPtr.Immutable = node.Constant
+			e.globals[node.Name.Value] = vPtr
+
+			return right, vt
+		}
+
+		vPtr := e.currBlock.NewAlloca(lt)
+		e.bindVariable(node.Name, vPtr)
+		e.currBlock.NewStore(right, vPtr)
+		return right, vt
+	case *parser.DestructureStatement:
+		right, vt := e.Emit(node.Right)
+		if len(vt.Tuple) != len(node.Names) {
+			e.appendError(node.Position(), diagnostics.CodeNotDestructured, "cannot destructure value of type %s into %d variables", vt, len(node.Names))
+			return nil, lexer.VarType{}
+		}
+		for i, name := range node.Names {
+			lt := e.varTypeToLlvm(node.Types[i])
+			vPtr := e.currBlock.NewAlloca(lt)
+			e.currBlock.NewStore(e.currBlock.NewExtractValue(right, uint64(i)), vPtr)
+			e.bindVariable(name, vPtr)
+		}
+		return right, vt
+	case *parser.FunctionStatement:
+		if node.Extern {
+			var paramGlTypes []lexer.VarType
+			for _, p := range node.Params {
+				paramGlTypes = append(paramGlTypes, p.Type)
+			}
+			fncPtr := e.declare(Declare{
+				Name:       node.Name.Value,
+				ReturnType: node.Type,
+				ParamTypes: paramGlTypes,
+				Variadic:   node.Variadic,
+				Extern:     true,
+			})
+			return fncPtr, node.Type
+		}
+		if node.Variadic && !e.genericVaArg {
+			e.appendError(node.Position(), diagnostics.CodeUnsupportedTarget, "variadic function %s can't be defined for this target, as va_arg isn't supported by its abi", node.Name.Value)
+		}
+		retType := e.varTypeToLlvm(node.Type)
+		var paramTypes []*ir.Param
+
+		for _, p := range node.Params {
+			irParam := ir.NewParam(
+				p.Name.Value,
+				e.varTypeToLlvm(p.Type),
+			)
+			if node.Exported {
+				addParamExt(irParam, p.Type)
+			}
+			e.parameters[p.Name.Value] = irParam
+			paramTypes = append(paramTypes, irParam)
+		}
+
+		fncPtr := e.m.NewFunc(node.Name.Value, retType, paramTypes...)
+		fncPtr.Sig.Variadic = node.Variadic
+		if node.Exported {
+			addReturnExt(fncPtr, node.Type)
+		}
+		// gl3 has no exceptions, so no call out of a gl3 function can unwind back through it
+		fncPtr.FuncAttrs = append(fncPtr.FuncAttrs, enum.FuncAttrNoUnwind)
+		// everything else can still be linked against by the other files of the program or library, but isn't in a
+		// shared library's dynamic symbol table
+		if !node.Exported && node.Name.Value != "main" {
+			fncPtr.Visibility = enum.VisibilityHidden
+		}
+		e.functions[node.Name.Value] = fncPtr
+		e.currBlock = fncPtr.NewBlock("")
+		e.currFnc = fncPtr
+		e.currFncVariadic = node.Variadic
+		e.currFncGlType = node.Type
+
+		// the checker has rejected non-void functions that can reach the end of their body, so a block left open here
+		// is either a none function falling off the end or dead code after an if/else that returns on both sides
+		if !e.emitBlockFindRet(node.Body) {
+			if node.Type.Equals(lexer.VarType{Base: lexer.Void}) {
+				e.currBlock.NewRet(nil)
+			} else {
+				e.currBlock.NewUnreachable()
+			}
+		}
+
+		e.parameters = make(map[string]*ir.Param)
+		e.variables = make(map[*parser.Symbol]*ir.InstAlloca)
+		return fncPtr, node.Type
+	case *parser.ReturnStatement:
+		if node.Expr == nil {
+			e.emitDefers(0)
+			e.currBlock.NewRet(nil)
+			return nil, lexer.VarType{Base: lexer.Void}
+		}
+		val, vt := e.Emit(node.Expr)
+		e.emitDefers(0)
+		e.currBlock.NewRet(val)
+		return val, vt
+	case *parser.ImportStatement:
+		if strings.HasSuffix(node.Path, ".gl3") {
+			f, err := os.ReadFile(node.Path)
+			if err != nil {
+				e.appendError(node.Position(), diagnostics.CodeImportNotFound, "cannot find %s file described in import stmt", f)
+				return nil, lexer.VarType{}
+			}
+			declares, globalDeclares, structs := FindDeclares(string(f))
+			// structs first, the declarations may use them
+			for _, st := range structs {
+				e.Emit(st)
+			}
+			for _, d := range declares {
+				e.declare(d)
+			}
+			for _, d := range globalDeclares {
+				e.declareGlobal(d)
+			}
+		} else if node.Path == "asm" {
+			e.asmModuleImported = true
+		} else {
+			if node.Path == "io" {
+				e.ioModuleImported = true
+			}
+			err := AddBuiltinModule(e, node.Path)
+			if err != nil {
+				e.appendError(node.Position(), diagnostics.CodeImportNotFound, "couldn't import builtin module %s", node.Path)
+			}
+		}
+	case *parser.IfStatement:
+		cond, _ := e.Emit(node.Condition)
+		thenBlock := e.currFnc.NewBlock("")
+		endBlock := e.currFnc.NewBlock("")
+
+		if node.Fail != nil {
+			elseBlock := e.currFnc.NewBlock("")
+			e.currBlock.NewCondBr(cond, thenBlock, elseBlock)
+
+			e.currBlock = thenBlock
+			saved := e.saveVariableState()
+			if !e.emitBlockFindRet(node.Success) {
+				e.currBlock.NewBr(endBlock)
+			}
+			e.loadVariableState(saved)
+			e.currBlock = elseBlock
+
+			saved = e.saveVariableState()
+			if !e.emitBlockFindRet(node.Fail) {
+				e.currBlock.NewBr(endBlock)
+			}
+			e.currBlock = endBlock
+			e.loadVariableState(saved)
+		} else {
+			e.currBlock.NewCondBr(cond, thenBlock, endBlock)
+			e.currBlock = thenBlock
+
+			saved := e.saveVariableState()
+			if !e.emitBlockFindRet(node.Success) {
+				e.currBlock.NewBr(endBlock)
+			}
+			e.currBlock = endBlock
+			e.loadVariableState(saved)
+		}
+	case *parser.WhileStatement:
+		condBlock := e.currFnc.NewBlock("")
+		whileBlock := e.currFnc.NewBlock("")
+		endBlock := e.currFnc.NewBlock("")
+
+		// emit branch to cond block
+		e.currBlock.NewBr(condBlock)
+
+		// emit condition block -- shouldnt need context saving since you cant set variables in boolean expr
+		// needs to be checked at compile time
+		e.currBlock = condBlock
+		cond, _ := e.Emit(node.Condition)
+		e.currBlock.NewCondBr(cond, whileBlock, endBlock)
+
+		// emit while block
+		saved := e.saveVariableState()
+		e.whileStack = append(e.whileStack, WhileLoopState{condBlock: condBlock, endBlock: endBlock, deferDepth: len(e.deferStack)})
+		e.currBlock = whileBlock
+		e.pushDeferFrame()
+		for _, s := range node.Body.Statements {
+			e.Emit(s)
+			if e.currBlock.Term != nil {
+				break
+			}
+		}
+		e.popDeferFrame()
+		if e.currBlock.Term == nil {
+			e.currBlock.NewBr(condBlock)
+		}
+		e.loadVariableState(saved)
+		e.whileStack = e.whileStack[:len(e.whileStack)-1]
+
+		e.currBlock = endBlock
+	case *parser.BreakStatement:
+		if len(e.whileStack) == 0 {
+			e.appendError(node.Position(), diagnostics.CodeOutsideLoop, "break statement not allowed outside of while loop")
+			return nil, lexer.VarType{}
+		}
+
+		loop := e.whileStack[len(e.whileStack)-1]
+		e.emitDefers(loop.deferDepth)
+		e.currBlock.NewBr(loop.endBlock)
+		return nil, lexer.VarType{}
+	case *parser.ContinueStatement:
+		if len(e.whileStack) == 0 {
+			e.appendError(node.Position(), diagnostics.CodeOutsideLoop, "continue statement not allowed outside of while loop")
+			return nil, lexer.VarType{}
+		}
+
+		loop := e.whileStack[len(e.whileStack)-1]
+		e.emitDefers(loop.deferDepth)
+		e.currBlock.NewBr(loop.condBlock)
+		return nil, lexer.VarType{}
+	case *parser.DeferStatement:
+		if len(e.deferStack) == 0 {
+			e.appendError(node.Position(), diagnostics.CodeOutsideLoop, "defer statement not allowed outside of function body")
+			return nil, lexer.VarType{}
+		}
+		top := len(e.deferStack) - 1
+		e.deferStack[top] = append(e.deferStack[top], node.Expr)
+		return nil, lexer.VarType{}
+	case *parser.StructStatement:
+		// a struct both imported and defined again keeps the one type definition in the module
+		typ, ok := e.structTypes[node.Name]
+		if !ok {
+			typ = &types.StructType{
+				TypeName: node.Name,
+			}
+			e.m.NewTypeDef(node.Name, typ)
+		}
+		typ.Fields = nil
+		for _, t := range node.Types {
+			typ.Fields = append(typ.Fields, e.varTypeToLlvmStructDefn(t, node.Name))
+		}
+		e.structTypes[node.Name] = typ
+	}
+
+	return nil, lexer.VarType{}
+}
+
+// emitExpression emits the value of an expression, whose type is looked up with typeOf
+func (e *Emitter) emitExpression(node parser.Expression) value.Value {
+	switch node := node.(type) {
 	case *parser.IntegerLiteral:
 		/**
 		fnc main() -> int32 {
@@ -164,116 +456,106 @@ func (e *Emitter) Emit(node parser.Node) (value.Value, lexer.VarType) {
 		go has uint128
 		*/
 		if _, ok := glTypeSInts[node.Type.Base]; ok {
-			return constant.NewInt(e.varTypeToLlvm(node.Type).(*types.IntType), node.Value), node.Type
+			return constant.NewInt(e.varTypeToLlvm(node.Type).(*types.IntType), node.Value)
 		} else if _, ok := glTypeUInts[node.Type.Base]; ok {
-			return constant.NewInt(e.varTypeToLlvm(node.Type).(*types.IntType), int64(node.UValue)), node.Type
+			return constant.NewInt(e.varTypeToLlvm(node.Type).(*types.IntType), int64(node.UValue))
 		}
 	case *parser.BooleanExpression:
 		// if e.emittingVarargArgs {
 		// 	return constant.NewInt(types.I32, boolToI1(node.Value)), lexer.VarType{Base: lexer.Int32, Pointer: 0}
 		// }
-		return constant.NewInt(types.I1, boolToI1(node.Value)), lexer.VarType{Base: lexer.Bool, Pointer: 0}
+		return constant.NewInt(types.I1, boolToI1(node.Value))
 	case *parser.FloatLiteral:
-		return constant.NewFloat(types.Float, float64(node.Value)), lexer.VarType{Base: lexer.Float, Pointer: 0}
+		return constant.NewFloat(types.Float, float64(node.Value))
 	case *parser.InfixExpression:
 		left, leftVt := e.Emit(node.Left)
 		if node.Operator == "." {
-			// TODO: gep if ptr lhs
-			if !leftVt.IsStructType {
-				e.appendError(node.Position(), "non struct type %v on lhs of dot operator", leftVt)
-			}
-			var fieldName string
-			if ident, ok := node.Right.(*parser.IdentifierExpression); ok {
-				fieldName = ident.Value
-			} else {
-				e.appendError(node.Position(), "non identifier %T on rhs of dot operator", node.Right)
-			}
-
-			structType, ok := e.structTypes[leftVt.StructName]
+			field, ok := e.info.SymbolOf(node)
 			if !ok {
-				e.appendError(node.Position(), "couldn't find struct type %s in field access", leftVt.StructName)
+				e.appendError(node.Position(), diagnostics.CodeInternal, "unresolved field access on type %s", leftVt)
+				return nil
 			}
-			fieldIndexes, _ := e.structMemberIndexes[leftVt.StructName]
-			fieldIndex, ok := fieldIndexes[fieldName]
+			structType, ok := e.structTypes[field.Struct]
 			if !ok {
-				e.appendError(node.Position(), "couldn't find field %s on struct of type %s", fieldName, leftVt.StructName)
+				e.appendError(node.Position(), diagnostics.CodeUndefinedStruct, "couldn't find struct type %s in field access", field.Struct)
+				return nil
 			}
-			fieldType := e.structMemberTypes[leftVt.StructName][fieldIndex]
 			if leftVt.Pointer > 0 {
 				zero := constant.NewInt(types.I32, 0)
-				fieldIdxConst := constant.NewInt(types.I32, int64(fieldIndex))
-				return e.currBlock.NewGetElementPtr(structType, left, zero, fieldIdxConst), fieldType
+				fieldIdxConst := constant.NewInt(types.I32, int64(field.Index))
+				gep := e.currBlock.NewGetElementPtr(structType, left, zero, fieldIdxConst)
+				return e.currBlock.NewLoad(structType.Fields[field.Index], gep)
 			} else {
-				return e.currBlock.NewExtractValue(left, uint64(fieldIndex)), fieldType
+				return e.currBlock.NewExtractValue(left, uint64(field.Index))
 			}
 		}
+		if node.Operator == "orelse" {
+			return e.emitOrElse(node, left, leftVt)
+		}
 		right, rightVt := e.Emit(node.Right)
 
-		leftType := left.Type()
-		rightType := right.Type()
-
 		_, leftIntBaseOk := infixIntOpTypes[leftVt.Base]
 		_, rightIntBaseOk := infixIntOpTypes[rightVt.Base]
 		leftIntOk := leftIntBaseOk && leftVt.Pointer == 0
 		rightIntOk := rightIntBaseOk && rightVt.Pointer == 0
 
 		// TODO: extend when doubles etc
-		_, leftFloatOk := leftType.(*types.FloatType)
-		_, rightFloatOk := rightType.(*types.FloatType)
+		leftFloatOk := leftVt.Base == lexer.Float && leftVt.Pointer == 0
+		rightFloatOk := rightVt.Base == lexer.Float && rightVt.Pointer == 0
 
-		if ptr, ok := leftType.(*types.PointerType); ok && rightIntOk {
+		if ptr, ok := left.Type().(*types.PointerType); ok && rightIntOk {
 			if node.Operator == "+" {
-				return e.currBlock.NewGetElementPtr(ptr.ElemType, left, right), leftVt
+				return e.currBlock.NewGetElementPtr(ptr.ElemType, left, right)
 			} else if node.Operator == "-" {
-				intType := rightType.(*types.IntType)
+				intType := right.Type().(*types.IntType)
 				zero := constant.NewInt(intType, 0)
 				negRight := e.currBlock.NewSub(zero, right)
-				return e.currBlock.NewGetElementPtr(ptr.ElemType, left, negRight), leftVt
+				return e.currBlock.NewGetElementPtr(ptr.ElemType, left, negRight)
 			}
 		}
 
 		// TODO: dodgy int8/char hack, improve soon
-		if leftIntOk && rightIntOk && ((leftVt == rightVt) || (leftVt.Base == lexer.Char && rightVt.Base == lexer.Int8)) {
+		if leftIntOk && rightIntOk && ((leftVt.Equals(rightVt)) || (leftVt.Base == lexer.Char && rightVt.Base == lexer.Int8)) {
 			switch node.Operator {
 			case "+":
-				return e.currBlock.NewAdd(left, right), leftVt
+				return e.currBlock.NewAdd(left, right)
 			case "-":
-				return e.currBlock.NewSub(left, right), leftVt
+				return e.currBlock.NewSub(left, right)
 			case "*":
-				return e.currBlock.NewMul(left, right), leftVt
+				return e.currBlock.NewMul(left, right)
 			case "==":
-				return e.currBlock.NewICmp(enum.IPredEQ, left, right), leftVt
+				return e.currBlock.NewICmp(enum.IPredEQ, left, right)
 			case "!=":
-				return e.currBlock.NewICmp(enum.IPredNE, left, right), leftVt
+				return e.currBlock.NewICmp(enum.IPredNE, left, right)
 			}
 
 			if _, ok := glTypeUInts[leftVt.Base]; ok {
 				switch node.Operator {
 				case "/":
 					// TODO: def behaviour for /0, intmin/-1
-					return e.currBlock.NewUDiv(left, right), leftVt
+					return e.currBlock.NewUDiv(left, right)
 				case "<":
-					return e.currBlock.NewICmp(enum.IPredULT, left, right), leftVt
+					return e.currBlock.NewICmp(enum.IPredULT, left, right)
 				case ">":
-					return e.currBlock.NewICmp(enum.IPredUGT, left, right), leftVt
+					return e.currBlock.NewICmp(enum.IPredUGT, left, right)
 				case "<=":
-					return e.currBlock.NewICmp(enum.IPredULE, left, right), leftVt
+					return e.currBlock.NewICmp(enum.IPredULE, left, right)
 				case ">=":
-					return e.currBlock.NewICmp(enum.IPredUGE, left, right), leftVt
+					return e.currBlock.NewICmp(enum.IPredUGE, left, right)
 				}
 			} else if _, ok := glTypeSInts[leftVt.Base]; ok {
 				switch node.Operator {
 				case "/":
 					// TODO: def behaviour for /0, intmin/-1
-					return e.currBlock.NewSDiv(left, right), leftVt
+					return e.currBlock.NewSDiv(left, right)
 				case "<":
-					return e.currBlock.NewICmp(enum.IPredSLT, left, right), leftVt
+					return e.currBlock.NewICmp(enum.IPredSLT, left, right)
 				case ">":
-					return e.currBlock.NewICmp(enum.IPredSGT, left, right), leftVt
+					return e.currBlock.NewICmp(enum.IPredSGT, left, right)
 				case "<=":
-					return e.currBlock.NewICmp(enum.IPredSLE, left, right), leftVt
+					return e.currBlock.NewICmp(enum.IPredSLE, left, right)
 				case ">=":
-					return e.currBlock.NewICmp(enum.IPredSGE, left, right), leftVt
+					return e.currBlock.NewICmp(enum.IPredSGE, left, right)
 				}
 			}
 		}
@@ -281,258 +563,222 @@ func (e *Emitter) Emit(node parser.Node) (value.Value, lexer.VarType) {
 		if leftFloatOk && rightFloatOk {
 			switch node.Operator {
 			case "+":
-				return e.currBlock.NewFAdd(left, right), leftVt
+				return e.currBlock.NewFAdd(left, right)
 			case "-":
-				return e.currBlock.NewFSub(left, right), leftVt
+				return e.currBlock.NewFSub(left, right)
 			case "*":
-				return e.currBlock.NewFMul(left, right), leftVt
+				return e.currBlock.NewFMul(left, right)
 			case "/":
 				// TODO: def behaviour for /0, intmin/-1
-				return e.currBlock.NewFDiv(left, right), leftVt
+				return e.currBlock.NewFDiv(left, right)
 			case "<":
-				return e.currBlock.NewFCmp(enum.FPredOLT, left, right), leftVt
+				return e.currBlock.NewFCmp(enum.FPredOLT, left, right)
 			case ">":
-				return e.currBlock.NewFCmp(enum.FPredOGT, left, right), leftVt
+				return e.currBlock.NewFCmp(enum.FPredOGT, left, right)
 			case "<=":
-				return e.currBlock.NewFCmp(enum.FPredOLE, left, right), leftVt
+				return e.currBlock.NewFCmp(enum.FPredOLE, left, right)
 			case ">=":
-				return e.currBlock.NewFCmp(enum.FPredOGE, left, right), leftVt
+				return e.currBlock.NewFCmp(enum.FPredOGE, left, right)
 			case "==":
-				return e.currBlock.NewFCmp(enum.FPredOEQ, left, right), leftVt
+				return e.currBlock.NewFCmp(enum.FPredOEQ, left, right)
 			case "!=":
-				return e.currBlock.NewFCmp(enum.FPredONE, left, right), leftVt
+				return e.currBlock.NewFCmp(enum.FPredONE, left, right)
 			}
 		}
 
-		if leftType == types.I1 && rightType == types.I1 {
+		if leftVt.Equals(lexer.VarType{Base: lexer.Bool}) && rightVt.Equals(lexer.VarType{Base: lexer.Bool}) {
 			switch node.Operator {
 			case "&&":
-				return e.currBlock.NewAnd(left, right), leftVt
+				return e.currBlock.NewAnd(left, right)
 			case "||":
-				return e.currBlock.NewOr(left, right), leftVt
+				return e.currBlock.NewOr(left, right)
 			}
 		}
 
-		e.appendError(node.Position(), "operator %s invalid for types %T(%s), %T(%s)", node.Operator, node.Left, node.Left.String(), node.Right, node.Right.String())
+		e.appendError(node.Position(), diagnostics.CodeBadOperator, "operator %s invalid for types %T(%s), %T(%s)", node.Operator, node.Left, node.Left.String(), node.Right, node.Right.String())
 	case *parser.PrefixExpression:
 		switch node.Operator {
 		case "!":
-			right, rt := e.Emit(node.Right)
-			// TODO: check right == i1
+			right, _ := e.Emit(node.Right)
 			trueVal := constant.NewInt(types.I1, 1)
-			return e.currBlock.NewXor(right, trueVal), rt
+			return e.currBlock.NewXor(right, trueVal)
 		case "-":
 			right, rt := e.Emit(node.Right)
 			_, rightIntOk := glTypeSInts[rt.Base]
-			_, rightFloatOk := right.Type().(*types.FloatType)
+			rightFloatOk := rt.Base == lexer.Float && rt.Pointer == 0
+			// negated literals are folded, so they can initialize globals which are emitted outside any block
+			if c, ok := right.(*constant.Int); ok && rightIntOk {
+				return &constant.Int{Typ: c.Typ, X: new(big.Int).Neg(c.X)}
+			} else if c, ok := right.(*constant.Float); ok && rightFloatOk {
+				return &constant.Float{Typ: c.Typ, X: new(big.Float).Neg(c.X)}
+			}
 			if rightIntOk {
 				zero := constant.NewInt(right.Type().(*types.IntType), 0)
-				return e.currBlock.NewSub(zero, right), rt
+				return e.currBlock.NewSub(zero, right)
 			} else if rightFloatOk {
 				zero := constant.NewFloat(types.Float, 0)
-				return e.currBlock.NewFSub(zero, right), rt
+				return e.currBlock.NewFSub(zero, right)
 			}
 		}
-	case *parser.DefStatement:
-		lt := e.varTypeToLlvm(node.Type)
-		right, vt := e.Emit(node.Right)
-
-		if node.Global {
-			vPtr := e.m.NewGlobal(node.Name.Value, lt)
-
-			if _, ok := right.(constant.Constant); !ok {
-				e.appendError(node.Position(), "global variable must be initialized with a constant value")
-			}
-			vPtr.Init = right.(constant.Constant)
-			vPtr.Immutable = node.Constant
-			e.globals[node.Name.Value] = vPtr
-			e.varTypes[node.Name.Value] = lt
-			e.varGlTypes[node.Name.Value] = vt
-
-			return right, vt
+	case *parser.TupleExpression:
+		return e.emitTuple(node)
+	case *parser.NullLiteral:
+		// the checker gives null the optional type of wherever it's used
+		vt := e.typeOf(node)
+		if !vt.Optional {
+			e.appendError(node.Position(), diagnostics.CodeBadWrap, "cannot infer the optional type of null here, use it as a def, assignment, return value or call argument")
+			return nil
 		}
-
-		vPtr := e.currBlock.NewAlloca(lt)
-		e.variables[node.Name.Value] = vPtr
-		e.varTypes[node.Name.Value] = lt
-		e.varGlTypes[node.Name.Value] = vt
-		e.currBlock.NewStore(right, vPtr)
-		return right, vt
+		return e.emitWrapped(vt, nil, nil)
+	case *parser.WrapExpression:
+		vt := e.typeOf(node)
+		if !vt.IsWrapped() {
+			e.appendError(node.Position(), diagnostics.CodeBadWrap, "cannot infer the type of %s here, use it as a def, assignment, return value or call argument", node.Token.Literal)
+			return nil
+		}
+		return e.emitWrap(node, vt)
+	case *parser.TryExpression:
+		return e.emitTry(node)
 	case *parser.AssignmentExpression:
 		if ident, ok := node.Left.(*parser.IdentifierExpression); ok {
-			right, vt := e.Emit(node.Right)
-			if global, ok := e.globals[ident.Value]; ok {
-				e.currBlock.NewStore(right, global)
-				return right, vt
+			right, _ := e.Emit(node.Right)
+			if sym, ok := e.info.SymbolOf(ident); ok && sym.Kind == parser.GlobalSymbol {
+				e.currBlock.NewStore(right, e.globals[ident.Value])
+				return right
 			}
 
-			vPtr, ok := e.variables[ident.Value]
+			vPtr, ok := e.variable(ident)
 			if !ok {
-				e.appendError(node.Position(), "couldn't find variable of name %s used in var assignment", ident.Value)
+				e.appendError(node.Position(), diagnostics.CodeUndefinedVariable, "couldn't find variable of name %s used in var assignment", ident.Value)
+				return nil
 			}
 			e.currBlock.NewStore(right, vPtr)
-			return right, vt
+			return right
 		} else if _, ok := node.Left.(*parser.DereferenceExpression); ok {
-			ptr, _ := e.emitAddress(node.Left)
-			right, vt := e.Emit(node.Right)
+			ptr := e.emitAddress(node.Left)
+			right, _ := e.Emit(node.Right)
 			e.currBlock.NewStore(right, ptr)
-			return right, vt
+			return right
 		} else if infix, ok := node.Left.(*parser.InfixExpression); ok && infix.Operator == "." {
-			var name string
-			if ident, ok := infix.Left.(*parser.IdentifierExpression); ok {
-				name = ident.Value
-			} else {
-				e.appendError(node.Position(), "expected identifier on lhs of dot operator")
+			ident, ok := infix.Left.(*parser.IdentifierExpression)
+			if !ok {
+				e.appendError(node.Position(), diagnostics.CodeBadAssignTarget, "expected identifier on lhs of dot operator")
+				return nil
 			}
 			left, leftVt := e.Emit(infix.Left)
 			right, _ := e.Emit(node.Right)
-			structType, ok := e.structTypes[leftVt.StructName]
+			field, ok := e.info.SymbolOf(infix)
 			if !ok {
-				e.appendError(node.Position(), "could not find struct with type %s", leftVt.StructName)
-			}
-			var fieldName string
-			if ident, ok := infix.Right.(*parser.IdentifierExpression); ok {
-				fieldName = ident.Value
+				e.appendError(node.Position(), diagnostics.CodeInternal, "unresolved field assignment on type %s", leftVt)
+				return nil
 			}
-			fieldIdx, ok := e.structMemberIndexes[leftVt.StructName][fieldName]
+			structType, ok := e.structTypes[field.Struct]
 			if !ok {
-				e.appendError(node.Position(), "couldn't find field %s on struct of type %s", fieldName, leftVt.StructName)
+				e.appendError(node.Position(), diagnostics.CodeUndefinedStruct, "could not find struct with type %s", field.Struct)
 			}
+			fieldIdx := field.Index
 			if leftVt.Pointer > 0 {
 				zero := constant.NewInt(types.I32, 0)
 				fieldIdxConst := constant.NewInt(types.I32, int64(fieldIdx))
 				gep := e.currBlock.NewGetElementPtr(structType, left, zero, fieldIdxConst)
 				e.currBlock.NewStore(right, gep)
-				return gep, leftVt
+				return right
 			} else {
 				insert := e.currBlock.NewInsertValue(left, right, uint64(fieldIdx))
-				vPtr, ok := e.variables[name]
+				vPtr, ok := e.variable(ident)
 				if !ok {
-					e.appendError(node.Position(), "could not find variable with name %s", name)
+					e.appendError(node.Position(), diagnostics.CodeUndefinedVariable, "could not find variable with name %s", ident.Value)
+					return nil
 				}
 				e.currBlock.NewStore(insert, vPtr)
-				return insert, leftVt
+				return right
 			}
 		}
 	case *parser.IdentifierExpression:
-		if param, ok := e.parameters[node.Value]; ok {
+		sym, ok := e.info.SymbolOf(node)
+		if !ok {
+			e.appendError(node.Position(), diagnostics.CodeInternal, "unresolved identifier %s", node.Value)
+			return nil
+		}
+		switch sym.Kind {
+		case parser.ParameterSymbol:
 			// if e.emittingVarargArgs && param.Typ == types.I1 {
 			// 	return e.currBlock.NewZExt(param, types.I32), lexer.VarType{Base: lexer.Int32, Pointer: 0}
 			// }
-			return param, e.parametersGlTypes[node.Value]
+			return e.parameters[node.Value]
+		case parser.GlobalSymbol:
+			global := e.globals[node.Value]
+			return e.currBlock.NewLoad(global.ContentType, global)
 		}
 
-		if global, ok := e.globals[node.Value]; ok {
-			return e.currBlock.NewLoad(e.varTypes[node.Value], global), e.varGlTypes[node.Value]
-		}
-
-		vPtr, ok := e.variables[node.Value]
-		if !ok {
-			e.appendError(node.Position(), "couldn't find variable of name %s used in var ref", node.Value)
-		}
-		vType, ok := e.varTypes[node.Value]
+		vPtr, ok := e.variables[sym]
 		if !ok {
-			e.appendError(node.Position(), "couldn't find variable type of name %s used in var ref", node.Value)
+			e.appendError(node.Position(), diagnostics.CodeUndefinedVariable, "couldn't find variable of name %s used in var ref", node.Value)
+			return nil
 		}
-		load := e.currBlock.NewLoad(vType, vPtr)
+		load := e.currBlock.NewLoad(vPtr.ElemType, vPtr)
 		// if e.emittingVarargArgs && vType == types.I1 {
 		// 	return e.currBlock.NewZExt(load, types.I32), lexer.VarType{Base: lexer.Int32, Pointer: 0}
 		// }
-		return load, e.varGlTypes[node.Value]
+		return load
 	case *parser.CallExpression:
 		if _, ok := e.astFuncs[node.Function.Value]; ok && e.asmModuleImported {
 			// NOTE: maybe pass node directly to emitAsmIntrinsic ? computing .Position() when it might not be used seems wasteful
 			return e.emitAsmIntrinsic(node.Position(), node.Function.Value, node.Params)
 		}
-		var args []value.Value
-
-		if e.ioModuleImported && node.Function.Value == "print" {
-			e.emittingVarargArgs = true
-		}
-
-		for _, a := range node.Params {
-			val, _ := e.Emit(a)
-			args = append(args, val)
+		if _, ok := e.vaFuncs[node.Function.Value]; ok {
+			return e.emitVaIntrinsic(node.Position(), node.Function.Value, node.Params)
 		}
-		e.emittingVarargArgs = false
-
 		fncPtr, ok := e.functions[node.Function.Value]
-		e.emittingVarargArgs = false
 		if !ok {
-			e.appendError(node.Position(), "couldn't find function with name %s", node.Function.Value)
+			e.appendError(node.Position(), diagnostics.CodeUndefinedFunction, "couldn't find function with name %s", node.Function.Value)
+			return nil
 		}
+		var args []value.Value
 
-		return e.currBlock.NewCall(fncPtr, args...), e.functionGlReturnTypes[node.Function.Value]
-	case *parser.FunctionStatement:
-		retType := e.varTypeToLlvm(node.Type)
-		var paramTypes []*ir.Param
-
-		for _, p := range node.Params {
-			irParam := ir.NewParam(
-				p.Name.Value,
-				e.varTypeToLlvm(p.Type),
-			)
-			e.parameters[p.Name.Value] = irParam
-			e.parametersGlTypes[p.Name.Value] = p.Type
-			paramTypes = append(paramTypes, irParam)
+		if e.ioModuleImported && node.Function.Value == "print" {
+			e.emittingVarargArgs = true
 		}
 
-		fncPtr := e.m.NewFunc(node.Name.Value, retType, paramTypes...)
-		e.functions[node.Name.Value] = fncPtr
-		e.currBlock = fncPtr.NewBlock("")
-		e.currFnc = fncPtr
-		e.functionGlReturnTypes[node.Name.Value] = node.Type
-
-		foundRet := false
-
-		for _, s := range node.Body.Statements {
-			// ehh??? maybe need to check if ret type none ? this allows an implicit return which i ... dislike
-			if _, ok := s.(*parser.ReturnStatement); !foundRet && ok {
-				foundRet = true
+		for i, a := range node.Params {
+			val, vt := e.Emit(a)
+			if fncPtr.Sig.Variadic && i >= len(fncPtr.Sig.Params) {
+				val = e.promoteVararg(val, vt)
 			}
-			e.Emit(s)
-		}
-
-		if !foundRet {
-			if node.Type.Base == lexer.None && !node.Type.IsStructType && node.Type.Pointer == 0 {
-				e.currBlock.NewRet(nil)
-			} else {
-				e.appendError(node.Position(), "missing return statement in non-void function")
+			// builtins take any pointer as an i8*, see AnyPointer
+			if i < len(fncPtr.Sig.Params) && vt.Pointer > 0 && !val.Type().Equal(fncPtr.Sig.Params[i]) {
+				val = e.currBlock.NewBitCast(val, fncPtr.Sig.Params[i])
+			}
+			// a va_list value points to the storage va_start filled in, where C takes the pointer stored there instead
+			if _, ok := e.cFunctions[node.Function.Value]; ok && e.vaListPointer && vt.Equals(lexer.VarType{Base: lexer.VaList}) {
+				val = e.currBlock.NewLoad(types.I8Ptr, e.currBlock.NewBitCast(val, types.NewPointer(types.I8Ptr)))
 			}
+			args = append(args, val)
 		}
+		e.emittingVarargArgs = false
 
-		e.parameters = make(map[string]*ir.Param)
-		e.parametersGlTypes = make(map[string]lexer.VarType)
-		e.variables = make(map[string]*ir.InstAlloca)
-		e.varTypes = make(map[string]types.Type)
-		e.varGlTypes = make(map[string]lexer.VarType)
-		return fncPtr, node.Type
-	case *parser.ReturnStatement:
-		val, vt := e.Emit(node.Expr)
-		e.currBlock.NewRet(val)
-		return val, vt
+		return e.currBlock.NewCall(fncPtr, args...)
 	case *parser.ReferenceExpression:
-		vPtr, ok := e.variables[node.Var.Value]
+		vPtr, ok := e.variable(node.Var)
 		if !ok {
-			e.appendError(node.Position(), "couldn't find variable with name %s in reference expr", node.Var.Value)
+			e.appendError(node.Position(), diagnostics.CodeUndefinedVariable, "couldn't find variable with name %s in reference expr", node.Var.Value)
+			return nil
 		}
-		t := e.varGlTypes[node.Var.Value]
-		t.Pointer++
-		return vPtr, t
+		return vPtr
 	case *parser.DereferenceExpression:
-		ptr, vt := e.Emit(node.Var)
+		ptr, _ := e.Emit(node.Var)
 
 		ptrTy, ok := ptr.Type().(*types.PointerType)
 		if !ok {
-			e.appendError(node.Position(), "cannot deref non-ptr type %v", ptrTy)
+			e.appendError(node.Position(), diagnostics.CodeBadAddress, "cannot deref non-ptr type %v", ptrTy)
+			return nil
 		}
-		vt.Pointer--
 
-		return e.currBlock.NewLoad(ptrTy.ElemType, ptr), vt
+		return e.currBlock.NewLoad(ptrTy.ElemType, ptr)
 	case *parser.CastExpression:
 		src, lt := e.Emit(node.Expr)
 		if (lt.IsStructType && lt.Pointer == 0) || (node.Type.IsStructType && node.Type.Pointer == 0) {
-			e.appendError(node.Position(), "casts using struct types are disallowed")
+			e.appendError(node.Position(), diagnostics.CodeBadCast, "casts using struct types are disallowed")
 		}
 		srcType := src.Type()
 		dstType := e.varTypeToLlvm(node.Type)
@@ -549,32 +795,28 @@ func (e *Emitter) Emit(node parser.Node) (value.Value, lexer.VarType) {
 
 		if leftIntOk && rightIntOk && node.Type.Pointer == 0 {
 			if srcType == types.I1 {
-				return e.currBlock.NewZExt(src, dstType), node.Type
+				return e.currBlock.NewZExt(src, dstType)
 			}
 
 			dstSize := e.getSizeForVarType(node.Type)
-			srcSize := getSizeForLlvmType(src.Type())
+			srcSize := e.getSizeForLlvmType(src.Type())
 
 			if srcSize < dstSize {
-				return e.currBlock.NewSExt(src, dstType), node.Type
+				return e.currBlock.NewSExt(src, dstType)
 			} else if srcSize > dstSize {
-				return e.currBlock.NewTrunc(src, dstType), node.Type
+				return e.currBlock.NewTrunc(src, dstType)
 			} else {
 				// same size, no cast necessary
-				return src, lt
+				return src
 			}
 		} else if leftIntOk && node.Type.Pointer > 0 {
-			return e.currBlock.NewIntToPtr(src, dstType), node.Type
+			return e.currBlock.NewIntToPtr(src, dstType)
 		} else if _, ok := src.Type().(*types.PointerType); ok && rightIntOk && node.Type.Pointer == 0 {
-			if node.Type.Base != lexer.Int {
-				// non 64 bit which is llvm default on most (i.e 64bit) systems
-				e.appendError(node.Position(), "compile warning: pointer to int cast may truncate")
-			}
-			return e.currBlock.NewPtrToInt(src, e.varTypeToLlvm(node.Type)), node.Type
+			return e.currBlock.NewPtrToInt(src, e.varTypeToLlvm(node.Type))
 		} else if leftIntOk && rightFloatOk {
-			return e.currBlock.NewSIToFP(src, dstType), node.Type
+			return e.currBlock.NewSIToFP(src, dstType)
 		} else if leftFloatOk && rightIntOk {
-			return e.currBlock.NewFPToSI(src, dstType), node.Type
+			return e.currBlock.NewFPToSI(src, dstType)
 		} else if leftPtrOk && rightPtrOk {
 			/** c bitcast behaviour
 			  int x = 1073741941; // 0100 0000 0000 0000 0000 0000 0111 0101 = 1073741941; as float = 2.somethingsomething
@@ -583,25 +825,25 @@ func (e *Emitter) Emit(node parser.Node) (value.Value, lexer.VarType) {
 			  printf("%.100f", *fx); == 2.somethingsomething
 			  return 0;
 			*/
-			return e.currBlock.NewBitCast(src, dstType), node.Type
+			return e.currBlock.NewBitCast(src, dstType)
 		}
 	case *parser.SizeofExpression:
-		return constant.NewInt(types.I64, e.getSizeForVarType(node.Type)), lexer.VarType{Base: lexer.Uint, Pointer: 0}
+		return e.sizeOf(node.Type)
 	case *parser.ArrayLiteral:
 		newFnc, ok := e.functions["arr_new"]
 		if !ok {
-			e.appendError(node.Position(), "cannot find arr_new while emitting array literal")
+			e.appendError(node.Position(), diagnostics.CodeModuleNotImported, "cannot find arr_new while emitting array literal")
 		}
 		push, ok := e.functions["arr_push"]
 		if !ok {
-			e.appendError(node.Position(), "cannot find arr_push while emitting array literal")
+			e.appendError(node.Position(), diagnostics.CodeModuleNotImported, "cannot find arr_push while emitting array literal")
 		}
 
-		sizeInt := constant.NewInt(types.I64, e.getSizeForVarType(node.Type))
+		sizeInt := e.sizeOf(node.Type)
 		newCall := e.currBlock.NewCall(newFnc, sizeInt)
-		node.Type.Pointer++
-		newCallCasted := e.currBlock.NewBitCast(newCall, e.varTypeToLlvm(node.Type))
-		ptr := e.currBlock.NewAlloca(e.varTypeToLlvm(node.Type))
+		arrType := e.varTypeToLlvm(e.typeOf(node))
+		newCallCasted := e.currBlock.NewBitCast(newCall, arrType)
+		ptr := e.currBlock.NewAlloca(arrType)
 		e.currBlock.NewStore(newCallCasted, ptr)
 		for _, elem := range node.Items {
 			v, _ := e.Emit(elem)
@@ -609,40 +851,11 @@ func (e *Emitter) Emit(node parser.Node) (value.Value, lexer.VarType) {
 		}
 
 		// possibly dangerous?
-		return newCallCasted, node.Type
-	case *parser.ImportStatement:
-		if strings.HasSuffix(node.Path, ".gl3") {
-			f, err := os.ReadFile(node.Path)
-			if err != nil {
-				e.appendError(node.Position(), "cannot find %s file described in import stmt", f)
-				return nil, lexer.VarType{}
-			}
-			declares := findDeclares(string(f))
-			for _, d := range declares {
-				var params []*ir.Param
-				for _, p := range d.ParamTypes {
-					params = append(params, ir.NewParam("", e.varTypeToLlvm(p)))
-				}
-				fnc := e.m.NewFunc(d.Name, e.varTypeToLlvm(d.ReturnType), params...)
-				e.functions[d.Name] = fnc
-				e.functionGlReturnTypes[d.Name] = d.ReturnType
-			}
-		} else if node.Path == "asm" {
-			e.asmModuleImported = true
-		} else {
-			if node.Path == "io" {
-				e.ioModuleImported = true
-			}
-			err := AddBuiltinModule(e, node.Path)
-			if err != nil {
-				e.appendError(node.Position(), "couldn't import builtin module %s", node.Path)
-			}
-		}
+		return newCallCasted
 	case *parser.StringLiteral:
-		sVt := lexer.VarType{Base: lexer.Char, Pointer: 1}
 		zero := constant.NewInt(types.I64, 0)
 		if sPtr, ok := e.stringLiterals[node.Value]; ok {
-			return constant.NewGetElementPtr(sPtr.ContentType, sPtr, zero, zero), sVt
+			return constant.NewGetElementPtr(sPtr.ContentType, sPtr, zero, zero)
 		}
 		str := e.m.NewGlobalDef("", constant.NewCharArrayFromString(node.Value))
 		str.Linkage = enum.LinkagePrivate
@@ -650,170 +863,254 @@ func (e *Emitter) Emit(node parser.Node) (value.Value, lexer.VarType) {
 
 		e.stringLiterals[node.Value] = str
 
-		return constant.NewGetElementPtr(str.ContentType, str, zero, zero), sVt
-	case *parser.IfStatement:
-		cond, _ := e.Emit(node.Condition)
-		thenBlock := e.currFnc.NewBlock("")
-		endBlock := e.currFnc.NewBlock("")
-
-		if node.Fail != nil {
-			elseBlock := e.currFnc.NewBlock("")
-			e.currBlock.NewCondBr(cond, thenBlock, elseBlock)
-
-			e.currBlock = thenBlock
-			saved := e.saveVariableState()
-			if !e.emitBlockFindRet(node.Success) {
-				e.currBlock.NewBr(endBlock)
+		return constant.NewGetElementPtr(str.ContentType, str, zero, zero)
+	case *parser.StructInitializationExpression:
+		structType, ok := e.structTypes[node.Name]
+		if !ok {
+			e.appendError(node.Position(), diagnostics.CodeUndefinedStruct, "couldnt find struct with name %s for initialization", node.Name)
+			return nil
+		}
+		// kept constant where possible so globals can be initialized with it, the checker has made sure those are
+		var fields []constant.Constant
+		var nonConst []int
+		var vals []value.Value
+		for i, expr := range node.Values {
+			out, _ := e.Emit(expr)
+			vals = append(vals, out)
+			if cnst, ok := out.(constant.Constant); ok {
+				fields = append(fields, cnst)
+			} else {
+				fields = append(fields, constant.NewZeroInitializer(structType.Fields[i]))
+				nonConst = append(nonConst, i)
 			}
-			e.loadVariableState(saved)
-			e.currBlock = elseBlock
+		}
+		var out value.Value = constant.NewStruct(structType, fields...)
+		for _, idx := range nonConst {
+			out = e.currBlock.NewInsertValue(out, vals[idx], uint64(idx))
+		}
+		return out
 
-			saved = e.saveVariableState()
-			if !e.emitBlockFindRet(node.Fail) {
-				e.currBlock.NewBr(endBlock)
-			}
-			e.currBlock = endBlock
-			e.loadVariableState(saved)
-		} else {
-			e.currBlock.NewCondBr(cond, thenBlock, endBlock)
-			e.currBlock = thenBlock
+	}
 
-			saved := e.saveVariableState()
-			if !e.emitBlockFindRet(node.Success) {
-				e.currBlock.NewBr(endBlock)
-			}
-			e.currBlock = endBlock
-			e.loadVariableState(saved)
-		}
-	case *parser.WhileStatement:
-		condBlock := e.currFnc.NewBlock("")
-		whileBlock := e.currFnc.NewBlock("")
-		endBlock := e.currFnc.NewBlock("")
+	return nil
+}
 
-		// emit branch to cond block
-		e.currBlock.NewBr(condBlock)
+// typeOf is the type the checker resolved for expr, which it has for every expression of a program that passed it
+func (e *Emitter) typeOf(expr parser.Expression) lexer.VarType {
+	vt, ok := e.info.TypeOf(expr)
+	if !ok {
+		e.appendError(expr.Position(), diagnostics.CodeInternal, "no type resolved for %s", expr)
+	}
+	return vt
+}
 
-		// emit condition block -- shouldnt need context saving since you cant set variables in boolean expr
-		// needs to be checked at compile time
-		e.currBlock = condBlock
-		cond, _ := e.Emit(node.Condition)
-		e.currBlock.NewCondBr(cond, whileBlock, endBlock)
+// emitTuple packs the items of a multiple value return into an anonymous struct
+func (e *Emitter) emitTuple(node *parser.TupleExpression) value.Value {
+	var vals []value.Value
+	for _, item := range node.Items {
+		val, _ := e.Emit(item)
+		vals = append(vals, val)
+	}
+	// built up field by field as the items usually aren't constants
+	var tuple value.Value = constant.NewUndef(e.varTypeToLlvm(e.typeOf(node)))
+	for i, val := range vals {
+		tuple = e.currBlock.NewInsertValue(tuple, val, uint64(i))
+	}
+	return tuple
+}
 
-		// emit while block
-		saved := e.saveVariableState()
-		e.whileStack = append(e.whileStack, WhileLoopState{condBlock: condBlock, endBlock: endBlock})
-		e.currBlock = whileBlock
-		for _, s := range node.Body.Statements {
-			e.Emit(s)
-			if e.currBlock.Term != nil {
-				break
-			}
+// emitWrap emits some(x), ok(x) or err(x) as a value of type vt
+func (e *Emitter) emitWrap(node *parser.WrapExpression, vt lexer.VarType) value.Value {
+	switch node.Token.Literal {
+	case "some":
+		if !vt.Optional {
+			e.appendError(node.Position(), diagnostics.CodeBadWrap, "some used where non optional type %s is expected", vt)
+			return nil
 		}
-		if e.currBlock.Term == nil {
-			e.currBlock.NewBr(condBlock)
+	case "ok", "err":
+		if vt.ErrType == nil {
+			e.appendError(node.Position(), diagnostics.CodeBadWrap, "%s used where non result type %s is expected", node.Token.Literal, vt)
+			return nil
 		}
-		e.loadVariableState(saved)
-		e.whileStack = e.whileStack[:len(e.whileStack)-1]
+	}
 
-		e.currBlock = endBlock
-	case *parser.BreakStatement:
-		if len(e.whileStack) == 0 {
-			e.appendError(node.Position(), "break statement not allowed outside of while loop")
-			return nil, lexer.VarType{}
-		}
+	if node.Token.Literal == "err" {
+		errVal, _ := e.Emit(node.Value)
+		return e.emitWrapped(vt, nil, errVal)
+	}
+	val, _ := e.Emit(node.Value)
+	return e.emitWrapped(vt, val, nil)
+}
 
-		e.currBlock.NewBr(e.whileStack[len(e.whileStack)-1].endBlock)
-		return nil, lexer.VarType{}
-	case *parser.ContinueStatement:
-		if len(e.whileStack) == 0 {
-			e.appendError(node.Position(), "continue statement not allowed outside of while loop")
-			return nil, lexer.VarType{}
-		}
+// emitWrapped builds the { i1 ok, T value[, E err] } struct optionals and results are lowered to. a nil val and errVal
+// produces null
+func (e *Emitter) emitWrapped(vt lexer.VarType, val value.Value, errVal value.Value) value.Value {
+	structType := e.varTypeToLlvm(vt).(*types.StructType)
+	okFlag := constant.NewInt(types.I1, 0)
+	if val != nil {
+		okFlag = constant.NewInt(types.I1, 1)
+	}
 
-		e.currBlock.NewBr(e.whileStack[len(e.whileStack)-1].condBlock)
-		return nil, lexer.VarType{}
-	case *parser.StructStatement:
-		typ := &types.StructType{
-			TypeName: node.Name,
-		}
-		for _, t := range node.Types {
-			typ.Fields = append(typ.Fields, e.varTypeToLlvmStructDefn(t, node.Name))
-		}
-		e.structTypes[node.Name] = typ
-		e.structMemberIndexes[node.Name] = node.Names
-		e.structMemberTypes[node.Name] = node.Types
-		e.m.NewTypeDef(node.Name, typ)
-	case *parser.StructInitializationExpression:
-		structType, ok := e.structTypes[node.Name]
-		if !ok {
-			e.appendError(node.Position(), "couldnt find struct with name %s for initialization", node.Name)
+	// keep it constant where possible so globals can be initialized with it
+	fields := []constant.Constant{okFlag, constant.NewZeroInitializer(structType.Fields[1])}
+	if vt.ErrType != nil {
+		fields = append(fields, constant.NewZeroInitializer(structType.Fields[2]))
+	}
+	var nonConst []int
+	for i, v := range []value.Value{val, errVal} {
+		if v == nil {
+			continue
 		}
-		var fields []constant.Constant
-		for _, expr := range node.Values {
-			out, _ := e.Emit(expr)
-			if cnst, ok := out.(constant.Constant); ok {
-				fields = append(fields, cnst)
-			} else {
-				e.appendError(node.Position(), "non constant field in struct initialization")
-			}
+		if c, ok := v.(constant.Constant); ok {
+			fields[i+1] = c
+		} else {
+			nonConst = append(nonConst, i+1)
 		}
-		// lol initializing the vartype directly here is far easier (and probably more efficient) than storing it somewhere
-		return constant.NewStruct(structType, fields...), lexer.VarType{
-			IsStructType: true,
-			StructName:   node.Name,
+	}
+
+	var out value.Value = constant.NewStruct(structType, fields...)
+	for _, idx := range nonConst {
+		v := val
+		if idx == 2 {
+			v = errVal
 		}
+		out = e.currBlock.NewInsertValue(out, v, uint64(idx))
 	}
+	return out
+}
 
-	return nil, lexer.VarType{}
+// emitTry unwraps an optional or result, branching to an early return of null or the same error when it's empty
+func (e *Emitter) emitTry(node *parser.TryExpression) value.Value {
+	val, vt := e.Emit(node.Expr)
+	if !vt.IsWrapped() {
+		e.appendError(node.Position(), diagnostics.CodeBadUnwrap, "try used on non optional, non result type %s", vt)
+		return val
+	}
+	retVt := e.currFncGlType
+	if vt.ErrType != nil && retVt.ErrType != nil && !vt.ErrType.Equals(*retVt.ErrType) {
+		e.appendError(node.Position(), diagnostics.CodeBadUnwrap, "try on result with error type %s in function returning error type %s", vt.ErrType, retVt.ErrType)
+		return nil
+	}
+	if !retVt.Optional && !(vt.ErrType != nil && retVt.ErrType != nil) {
+		e.appendError(node.Position(), diagnostics.CodeBadUnwrap, "try on %s needs the enclosing function to return an optional or a result with the same error type, got %s", vt, retVt)
+		return nil
+	}
+
+	okFlag := e.currBlock.NewExtractValue(val, 0)
+	failBlock := e.currFnc.NewBlock("")
+	contBlock := e.currFnc.NewBlock("")
+	e.currBlock.NewCondBr(okFlag, contBlock, failBlock)
+
+	e.currBlock = failBlock
+	var failVal value.Value
+	if retVt.Optional {
+		failVal = e.emitWrapped(retVt, nil, nil)
+	} else {
+		failVal = e.emitWrapped(retVt, nil, e.currBlock.NewExtractValue(val, 2))
+	}
+	e.emitDefers(0)
+	e.currBlock.NewRet(failVal)
+
+	e.currBlock = contBlock
+	return e.currBlock.NewExtractValue(val, 1)
+}
+
+// emitOrElse emits x orelse y, which is the value in x if there is one and y otherwise. y is only evaluated when needed
+func (e *Emitter) emitOrElse(node *parser.InfixExpression, left value.Value, leftVt lexer.VarType) value.Value {
+	if !leftVt.IsWrapped() {
+		e.appendError(node.Position(), diagnostics.CodeBadUnwrap, "orelse used on non optional, non result type %s", leftVt)
+		return left
+	}
+	okFlag := e.currBlock.NewExtractValue(left, 0)
+	present := e.currBlock.NewExtractValue(left, 1)
+	fromBlock := e.currBlock
+	elseBlock := e.currFnc.NewBlock("")
+	endBlock := e.currFnc.NewBlock("")
+	e.currBlock.NewCondBr(okFlag, endBlock, elseBlock)
+
+	e.currBlock = elseBlock
+	fallback, _ := e.Emit(node.Right)
+	// the fallback may have branched itself, so the phi has to come from wherever it ended up
+	elseEnd := e.currBlock
+	e.currBlock.NewBr(endBlock)
+
+	e.currBlock = endBlock
+	phi := e.currBlock.NewPhi(ir.NewIncoming(present, fromBlock), ir.NewIncoming(fallback, elseEnd))
+	return phi
 }
 
 // this design is a little strange but it becomes very awkward to wire the blocks in this function specifically so i prefer to do it in the callers space and handle the not found return there
 func (e *Emitter) emitBlockFindRet(block *parser.BlockStatement) bool {
+	e.pushDeferFrame()
 	for _, s := range block.Statements {
 		e.Emit(s)
 		if e.currBlock.Term != nil {
+			e.popDeferFrame()
 			return true
 		}
 	}
+	e.popDeferFrame()
 	return false
 }
 
+func (e *Emitter) pushDeferFrame() {
+	e.deferStack = append(e.deferStack, nil)
+}
+
+// popDeferFrame closes the innermost block, running its defers on fallthrough. if the block already ended in a
+// ret/br the exit paths have run them already
+func (e *Emitter) popDeferFrame() {
+	if e.currBlock.Term == nil {
+		e.emitDefers(len(e.deferStack) - 1)
+	}
+	e.deferStack = e.deferStack[:len(e.deferStack)-1]
+}
+
+// emitDefers emits every deferred expression in frames above depth, innermost frame and latest defer first
+func (e *Emitter) emitDefers(depth int) {
+	for i := len(e.deferStack) - 1; i >= depth; i-- {
+		frame := e.deferStack[i]
+		for j := len(frame) - 1; j >= 0; j-- {
+			e.Emit(frame[j])
+		}
+	}
+}
+
 // emitAdress literally only necessary because i need the vptr from ident expr, deref expr is same as e.emit lol
-func (e *Emitter) emitAddress(node parser.Node) (value.Value, lexer.VarType) {
+func (e *Emitter) emitAddress(node parser.Node) value.Value {
 	switch node := node.(type) {
 	case *parser.IdentifierExpression:
-		if param, ok := e.parameters[node.Value]; ok {
-			return param, e.parametersGlTypes[node.Value]
+		if sym, ok := e.info.SymbolOf(node); ok && sym.Kind == parser.ParameterSymbol {
+			return e.parameters[node.Value]
 		}
-		vPtr, ok := e.variables[node.Value]
+		vPtr, ok := e.variable(node)
 		if !ok {
-			e.appendError(node.Position(), "couldn't find variable with name %s in deref assignment", node.Value)
+			e.appendError(node.Position(), diagnostics.CodeUndefinedVariable, "couldn't find variable with name %s in deref assignment", node.Value)
+			return nil
 		}
-		vt := e.varGlTypes[node.Value]
-		vt.Pointer++
-		return vPtr, vt
+		return vPtr
 	case *parser.DereferenceExpression:
-		ptr, t := e.Emit(node.Var)
-		return ptr, t
+		ptr, _ := e.Emit(node.Var)
+		return ptr
 	default:
-		e.appendError(node.Position(), "invalid node type for emitAddress")
-		return nil, lexer.VarType{}
+		e.appendError(node.Position(), diagnostics.CodeInternal, "invalid node type for emitAddress")
+		return nil
 	}
 }
 
-func (e *Emitter) emitAsmIntrinsic(pos *util.Position, fnc string, args []parser.Expression) (value.Value, lexer.VarType) {
+func (e *Emitter) emitAsmIntrinsic(pos *util.Position, fnc string, args []parser.Expression) value.Value {
 	switch fnc {
 	case "__asm__salloc":
 		if len(args) != 2 {
-			e.appendError(pos, "invalid amount of arguments for __asm__salloc: %d", len(args))
+			e.appendError(pos, diagnostics.CodeArgumentCount, "invalid amount of arguments for __asm__salloc: %d", len(args))
 		}
 		size, ok := args[0].(*parser.IntegerLiteral)
 		if !ok {
-			e.appendError(pos, "first argument of __asm__salloc is not integer: %T", args[0])
+			e.appendError(pos, diagnostics.CodeBadIntrinsic, "first argument of __asm__salloc is not integer: %T", args[0])
 		}
 		sizeof, ok := args[1].(*parser.SizeofExpression)
 		if !ok {
-			e.appendError(pos, "second argument of __asm__salloc is not sizeof expr: %T", args[1])
+			e.appendError(pos, diagnostics.CodeBadIntrinsic, "second argument of __asm__salloc is not sizeof expr: %T", args[1])
 		}
 		var arrSize uint64
 		if _, ok := glTypeUInts[size.Type.Base]; ok {
@@ -825,30 +1122,119 @@ func (e *Emitter) emitAsmIntrinsic(pos *util.Position, fnc string, args []parser
 		lt := types.NewArray(arrSize, e.varTypeToLlvm(vt))
 		ptr := e.currBlock.NewAlloca(lt)
 		vt.Pointer++
-		return e.currBlock.NewBitCast(ptr, e.varTypeToLlvm(vt)), vt // or gep into elem 0? this seems more suitable..
+		return e.currBlock.NewBitCast(ptr, e.varTypeToLlvm(vt))
 	default:
-		e.appendError(pos, "unknown asm intrinsic function: %s, %v", fnc, args)
-		return nil, lexer.VarType{}
+		e.appendError(pos, diagnostics.CodeBadIntrinsic, "unknown asm intrinsic function: %s, %v", fnc, args)
+		return nil
 	}
 }
 
-func (e *Emitter) saveVariableState() *VariableState {
-	state := &VariableState{
-		variables:  make(map[string]*ir.InstAlloca),
-		varTypes:   make(map[string]types.Type),
-		varGlTypes: make(map[string]lexer.VarType),
+// emitVaIntrinsic emits va_start(), va_arg(list, sizeof T) and va_end(list) for reading the trailing arguments of a
+// variadic function
+func (e *Emitter) emitVaIntrinsic(pos *util.Position, fnc string, args []parser.Expression) value.Value {
+	if !e.currFncVariadic {
+		e.appendError(pos, diagnostics.CodeBadIntrinsic, "%s used outside of a variadic function", fnc)
+		return nil
 	}
+	vaListVt := lexer.VarType{Base: lexer.VaList}
 
-	for k, v := range e.variables {
-		state.variables[k] = v
+	switch fnc {
+	case "va_start":
+		if len(args) != 0 {
+			e.appendError(pos, diagnostics.CodeArgumentCount, "invalid amount of arguments for va_start: %d", len(args))
+			return nil
+		}
+		// va_list is target specific, so it's kept opaque behind an i8* to storage large enough for any of them, 24
+		// bytes on x86_64 and 32 under AAPCS64. it only has to be large enough, as va_arg is only emitted for targets
+		// where llvm lowers it, see Target.GenericVaArg. calls into C load the va_list out of it where it's a plain
+		// pointer, see Target.VaListIsPointer
+		storage := e.currBlock.NewAlloca(types.NewArray(32, types.I8))
+		storage.Align = 16
+		list := e.currBlock.NewBitCast(storage, types.I8Ptr)
+		e.currBlock.NewCall(e.llvmIntrinsic("llvm.va_start"), list)
+		return list
+	case "va_arg":
+		if len(args) != 2 {
+			e.appendError(pos, diagnostics.CodeArgumentCount, "invalid amount of arguments for va_arg: %d", len(args))
+			return nil
+		}
+		list, listVt := e.Emit(args[0])
+		if !listVt.Equals(vaListVt) {
+			e.appendError(pos, diagnostics.CodeBadIntrinsic, "first argument of va_arg should be va_list, got %s", listVt)
+			return nil
+		}
+		sizeof, ok := args[1].(*parser.SizeofExpression)
+		if !ok {
+			e.appendError(pos, diagnostics.CodeBadIntrinsic, "second argument of va_arg is not sizeof expr: %T", args[1])
+			return nil
+		}
+		// callers promote the extra arguments as C does, so narrow types are read as what they were promoted to
+		promoted := promotedType(sizeof.Type)
+		if promoted == nil {
+			return e.currBlock.NewVAArg(list, e.varTypeToLlvm(sizeof.Type))
+		}
+		arg := e.currBlock.NewVAArg(list, promoted)
+		if sizeof.Type.Base == lexer.Float {
+			return e.currBlock.NewFPTrunc(arg, types.Float)
+		}
+		return e.currBlock.NewTrunc(arg, e.varTypeToLlvm(sizeof.Type))
+	case "va_end":
+		if len(args) != 1 {
+			e.appendError(pos, diagnostics.CodeArgumentCount, "invalid amount of arguments for va_end: %d", len(args))
+			return nil
+		}
+		list, listVt := e.Emit(args[0])
+		if !listVt.Equals(vaListVt) {
+			e.appendError(pos, diagnostics.CodeBadIntrinsic, "argument of va_end should be va_list, got %s", listVt)
+			return nil
+		}
+		e.currBlock.NewCall(e.llvmIntrinsic("llvm.va_end"), list)
+		return nil
+	default:
+		e.appendError(pos, diagnostics.CodeBadIntrinsic, "unknown va intrinsic function: %s, %v", fnc, args)
+		return nil
 	}
+}
 
-	for k, v := range e.varTypes {
-		state.varTypes[k] = v
+// llvmIntrinsic declares the given void(i8*) llvm intrinsic on first use
+func (e *Emitter) llvmIntrinsic(name string) *ir.Func {
+	if fnc, ok := e.functions[name]; ok {
+		return fnc
 	}
+	fnc := e.m.NewFunc(name, types.Void, ir.NewParam("", types.I8Ptr))
+	e.functions[name] = fnc
+	return fnc
+}
+
+// bindVariable stores where the local declared by name lives, under the checker's symbol for it. uses are found through
+// their symbols too rather than by name, so a deferred expression emitted at a block exit still reaches the variable
+// it named when it was deferred, not a later one shadowing it
+func (e *Emitter) bindVariable(name *parser.IdentifierExpression, vPtr *ir.InstAlloca) {
+	sym, ok := e.info.SymbolOf(name)
+	if !ok {
+		e.appendError(name.Position(), diagnostics.CodeInternal, "unresolved declaration of %s", name.Value)
+		return
+	}
+	e.variables[sym] = vPtr
+}
+
+// variable is where the local ident refers to lives
+func (e *Emitter) variable(ident *parser.IdentifierExpression) (*ir.InstAlloca, bool) {
+	sym, ok := e.info.SymbolOf(ident)
+	if !ok {
+		return nil, false
+	}
+	vPtr, ok := e.variables[sym]
+	return vPtr, ok
+}
 
-	for k, v := range e.varGlTypes {
-		state.varGlTypes[k] = v
+func (e *Emitter) saveVariableState() *VariableState {
+	state := &VariableState{
+		variables: make(map[*parser.Symbol]*ir.InstAlloca),
+	}
+
+	for k, v := range e.variables {
+		state.variables[k] = v
 	}
 
 	return state
@@ -856,11 +1242,20 @@ func (e *Emitter) saveVariableState() *VariableState {
 
 func (e *Emitter) loadVariableState(state *VariableState) {
 	e.variables = state.variables
-	e.varTypes = state.varTypes
-	e.varGlTypes = state.varGlTypes
+}
+
+// wrappedToLlvm lowers ?T to { i1, T } and T!E to { i1, T, E }, the i1 being whether a value is present
+func (e *Emitter) wrappedToLlvm(vt lexer.VarType, inner types.Type) types.Type {
+	if vt.ErrType != nil {
+		return types.NewStruct(types.I1, inner, e.varTypeToLlvm(*vt.ErrType))
+	}
+	return types.NewStruct(types.I1, inner)
 }
 
 func (e *Emitter) varTypeToLlvmStructDefn(vt lexer.VarType, currStructName string) types.Type {
+	if vt.IsWrapped() {
+		return e.wrappedToLlvm(vt, e.varTypeToLlvmStructDefn(vt.Unwrapped(), currStructName))
+	}
 	// TODO: bit of dupe code here, not sure how to resolve? don't want to integrate the struct stuff into reg vartype resolver as its only for structs
 	var baseType types.Type
 	if vt.IsStructType {
@@ -890,6 +1285,8 @@ func (e *Emitter) varTypeToLlvmStructDefn(vt lexer.VarType, currStructName strin
 			baseType = types.I1
 		case lexer.Float:
 			baseType = types.Float
+		case lexer.VaList:
+			baseType = types.I8Ptr
 		}
 	}
 
@@ -904,6 +1301,16 @@ func (e *Emitter) varTypeToLlvmStructDefn(vt lexer.VarType, currStructName strin
 
 func (e *Emitter) varTypeToLlvm(vt lexer.VarType) types.Type {
 	var baseType types.Type
+	if vt.IsWrapped() {
+		return e.wrappedToLlvm(vt, e.varTypeToLlvm(vt.Unwrapped()))
+	}
+	if vt.Tuple != nil {
+		var fields []types.Type
+		for _, t := range vt.Tuple {
+			fields = append(fields, e.varTypeToLlvm(t))
+		}
+		return types.NewStruct(fields...)
+	}
 	if vt.IsStructType {
 		baseType = e.structTypes[vt.StructName]
 	} else {
@@ -924,6 +1331,8 @@ func (e *Emitter) varTypeToLlvm(vt lexer.VarType) types.Type {
 			baseType = types.I1
 		case lexer.Float:
 			baseType = types.Float
+		case lexer.VaList:
+			baseType = types.I8Ptr
 		}
 	}
 
@@ -936,25 +1345,30 @@ func (e *Emitter) varTypeToLlvm(vt lexer.VarType) types.Type {
 	return baseType
 }
 
-func (e *Emitter) appendError(pos *util.Position, s string, v ...any) {
+func (e *Emitter) appendError(pos *util.Position, code string, s string, v ...any) {
 	e.Errors = append(e.Errors, util.PositionError{
 		Position: pos,
+		Code:     code,
 		Msg:      fmt.Sprintf(s, v...),
 	})
 }
 
+// sizeOf is the size of vt in bytes as a constant expression, the offset of the second element of an array of vt
+// starting at null. llvm works it out from the target's data layout, padding included, so it matches what loads,
+// stores and allocations of vt use
+func (e *Emitter) sizeOf(vt lexer.VarType) constant.Constant {
+	t := e.varTypeToLlvm(vt)
+	if t == nil || t.Equal(types.Void) {
+		return constant.NewInt(types.I64, 0)
+	}
+	elemPtr := constant.NewGetElementPtr(t, constant.NewNull(types.NewPointer(t)), constant.NewInt(types.I32, 1))
+	return constant.NewPtrToInt(elemPtr, types.I64)
+}
+
+// getSizeForVarType is the size in bytes of a scalar type, for choosing between extending and truncating in casts
 func (e *Emitter) getSizeForVarType(vt lexer.VarType) int64 {
 	if vt.Pointer > 0 {
-		return 8
-	}
-	if vt.IsStructType {
-		// validated by checker eventually
-		stFields, _ := e.structMemberTypes[vt.StructName]
-		var sum int64 = 0
-		for _, field := range stFields {
-			sum += e.getSizeForVarType(field)
-		}
-		return sum
+		return e.pointerSize
 	}
 	switch vt.Base {
 	case lexer.Bool, lexer.Int8, lexer.Uint8, lexer.Char:
@@ -965,12 +1379,15 @@ func (e *Emitter) getSizeForVarType(vt lexer.VarType) int64 {
 		return 4
 	case lexer.Int, lexer.Uint:
 		return 8
+	case lexer.VaList:
+		// kept behind an i8*, see emitVaIntrinsic
+		return e.pointerSize
 	}
 
 	return 0
 }
 
-func getSizeForLlvmType(lt types.Type) int64 {
+func (e *Emitter) getSizeForLlvmType(lt types.Type) int64 {
 	switch lt := lt.(type) {
 	case *types.IntType:
 		if lt.BitSize < 8 {
@@ -978,7 +1395,7 @@ func getSizeForLlvmType(lt types.Type) int64 {
 		}
 		return int64(lt.BitSize / 8)
 	case *types.PointerType:
-		return 8
+		return e.pointerSize
 	}
 
 	return 0
@@ -3,16 +3,34 @@ package emitter
 import (
 	"grianlang3/lexer"
 	"grianlang3/parser"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
 )
 
 type Declare struct {
 	Name       string
 	ReturnType lexer.VarType
 	ParamTypes []lexer.VarType
+	Variadic   bool
+	// Extern is whether the function is implemented in C, Exported whether it's a gl3 function callable from C. both
+	// follow the C abi's integer extension, matching how their definitions are emitted
+	Extern   bool
+	Exported bool
+}
+
+type GlobalDeclare struct {
+	Name     string
+	Type     lexer.VarType
+	Constant bool
 }
 
 type importParser struct {
-	declares []Declare
+	declares       []Declare
+	globalDeclares []GlobalDeclare
+	structs        []*parser.StructStatement
 }
 
 func (ip *importParser) findImports(node parser.Node) {
@@ -31,15 +49,130 @@ func (ip *importParser) findImports(node parser.Node) {
 			Name:       node.Name.Value,
 			ReturnType: node.Type,
 			ParamTypes: paramTypes,
+			Variadic:   node.Variadic,
+			Extern:     node.Extern,
+			Exported:   node.Exported,
+		})
+	case *parser.StructStatement:
+		ip.structs = append(ip.structs, node)
+	case *parser.DefStatement:
+		if !node.Global {
+			return
+		}
+		ip.globalDeclares = append(ip.globalDeclares, GlobalDeclare{
+			Name:     node.Name.Value,
+			Type:     node.Type,
+			Constant: node.Constant,
 		})
 	}
 }
 
-func findDeclares(file string) []Declare {
+// FindDeclares parses a .gl3 file and returns the functions, globals and structs it makes available to files importing
+// it
+func FindDeclares(file string) ([]Declare, []GlobalDeclare, []*parser.StructStatement) {
 	l := lexer.New(file)
 	p := parser.New(l)
 	program := p.ParseProgram()
 	ip := importParser{}
 	ip.findImports(program)
-	return ip.declares
+	return ip.declares, ip.globalDeclares, ip.structs
+}
+
+// declare adds an external function declaration to the module, a function already known is left as is
+func (e *Emitter) declare(d Declare) *ir.Func {
+	if fnc, ok := e.functions[d.Name]; ok {
+		return fnc
+	}
+	if d.Extern {
+		e.cFunctions[d.Name] = struct{}{}
+	}
+	var params []*ir.Param
+	for _, p := range d.ParamTypes {
+		param := ir.NewParam("", e.varTypeToLlvm(p))
+		if d.Extern || d.Exported {
+			addParamExt(param, p)
+		}
+		params = append(params, param)
+	}
+	fnc := e.m.NewFunc(d.Name, e.varTypeToLlvm(d.ReturnType), params...)
+	fnc.Sig.Variadic = d.Variadic
+	if d.Extern || d.Exported {
+		addReturnExt(fnc, d.ReturnType)
+	}
+	e.functions[d.Name] = fnc
+	return fnc
+}
+
+// the C abi passes and returns integers narrower than 32 bits extended to 32, which llvm only does for values marked
+// signext or zeroext. signed is whether vt is extended as a signed value, ok whether it's extended at all
+func abiExt(vt lexer.VarType) (signed bool, ok bool) {
+	if vt.Pointer > 0 || vt.IsStructType || vt.IsWrapped() || vt.Tuple != nil {
+		return false, false
+	}
+	switch vt.Base {
+	case lexer.Int8, lexer.Int16, lexer.Char:
+		return true, true
+	case lexer.Uint8, lexer.Uint16, lexer.Bool:
+		return false, true
+	}
+	return false, false
+}
+
+func addParamExt(param *ir.Param, vt lexer.VarType) {
+	if signed, ok := abiExt(vt); ok && signed {
+		param.Attrs = append(param.Attrs, enum.ParamAttrSignExt)
+	} else if ok {
+		param.Attrs = append(param.Attrs, enum.ParamAttrZeroExt)
+	}
+}
+
+func addReturnExt(fnc *ir.Func, vt lexer.VarType) {
+	if signed, ok := abiExt(vt); ok && signed {
+		fnc.ReturnAttrs = append(fnc.ReturnAttrs, enum.ReturnAttrSignExt)
+	} else if ok {
+		fnc.ReturnAttrs = append(fnc.ReturnAttrs, enum.ReturnAttrZeroExt)
+	}
+}
+
+// promotedType is the type C's default argument promotions give a value of type vt passed as an extra argument of a
+// variadic function, nil when it's passed as is
+func promotedType(vt lexer.VarType) types.Type {
+	if vt.Pointer > 0 || vt.IsStructType || vt.IsWrapped() || vt.Tuple != nil {
+		return nil
+	}
+	if vt.Base == lexer.Float {
+		return types.Double
+	}
+	if _, ok := abiExt(vt); ok {
+		return types.I32
+	}
+	return nil
+}
+
+// promoteVararg widens val the way C does before passing it as an extra argument of a variadic function, so C and gl3
+// functions reading it with va_arg find the type they expect
+func (e *Emitter) promoteVararg(val value.Value, vt lexer.VarType) value.Value {
+	to := promotedType(vt)
+	if to == nil {
+		return val
+	}
+	if vt.Base == lexer.Float {
+		return e.currBlock.NewFPExt(val, to)
+	}
+	if signed, _ := abiExt(vt); signed {
+		return e.currBlock.NewSExt(val, to)
+	}
+	return e.currBlock.NewZExt(val, to)
+}
+
+// declareGlobal adds an external global declaration to the module, a global already known is left as is
+func (e *Emitter) declareGlobal(d GlobalDeclare) *ir.Global {
+	if global, ok := e.globals[d.Name]; ok {
+		return global
+	}
+	global := e.m.NewGlobal(d.Name, e.varTypeToLlvm(d.Type))
+	global.Linkage = enum.LinkageExternal
+	global.Immutable = d.Constant
+	e.globals[d.Name] = global
+	return global
 }
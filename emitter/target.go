@@ -0,0 +1,134 @@
+package emitter
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Target is the machine code is generated for
+type Target struct {
+	Triple      string
+	DataLayout  string
+	PointerSize int64 // in bytes
+}
+
+// GenericVaArg is whether llvm's va_arg instruction reads the extra arguments of a variadic function correctly on the
+// target. it doesn't under AAPCS64, the aarch64 abi outside of Apple and Windows platforms, where va_list splits them
+// between the registers saved on entry and the stack, and llvm's lowering only reads the stack
+func (t Target) GenericVaArg() bool {
+	if t.Triple == "" {
+		return runtime.GOARCH != "arm64" || runtime.GOOS == "darwin" || runtime.GOOS == "ios" || runtime.GOOS == "windows"
+	}
+	arch, sys, _ := strings.Cut(t.Triple, "-")
+	if alias, ok := archAliases[arch]; ok {
+		arch = alias
+	}
+	if arch != "aarch64" {
+		return true
+	}
+	return strings.Contains(sys, "apple") || strings.Contains(sys, "darwin") || strings.Contains(sys, "macos") ||
+		strings.Contains(sys, "ios") || strings.Contains(sys, "windows")
+}
+
+// VaListIsPointer is whether C's va_list is passed as a plain pointer to the next extra argument on the target, as on
+// i386, Windows and Apple's aarch64, rather than as an array of one struct decaying to a pointer to it like on x86_64
+// elsewhere. a va_list of a struct holding only that pointer, as on arm, is passed the same way
+func (t Target) VaListIsPointer() bool {
+	if !t.GenericVaArg() {
+		// AAPCS64's va_list is a struct large enough to be passed by reference
+		return false
+	}
+	if t.Triple == "" {
+		return runtime.GOARCH != "amd64" || runtime.GOOS == "windows"
+	}
+	arch, sys, _ := strings.Cut(t.Triple, "-")
+	if alias, ok := archAliases[arch]; ok {
+		arch = alias
+	}
+	if arch != "x86_64" {
+		return true
+	}
+	return strings.Contains(sys, "windows") || strings.Contains(sys, "mingw")
+}
+
+// HostTarget leaves the triple and data layout out of the module for clang to fill in with the host's
+var HostTarget = Target{PointerSize: 8}
+
+type archLayout struct {
+	pointerSize int64
+	// layout is the data layout with a %s for the name mangling, which depends on the object file format
+	layout string
+	// osLayouts are the layouts of operating systems that differ by more than the mangling, keyed by darwin or windows
+	osLayouts map[string]string
+}
+
+// archLayouts are llvm's data layouts for the architectures gl3 can build for, keyed by the first part of the triple.
+// they're those of llvm 14, clang replaces them with its own when they've changed since
+var archLayouts = map[string]archLayout{
+	"x86_64": {pointerSize: 8, layout: "e-m:%s-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128"},
+	"i386": {
+		pointerSize: 4,
+		layout:      "e-m:%s-p:32:32-p270:32:32-p271:32:32-p272:64:64-f64:32:64-f80:32-n8:16:32-S128",
+		osLayouts: map[string]string{
+			"darwin":  "e-m:o-p:32:32-p270:32:32-p271:32:32-p272:64:64-f64:32:64-f80:128-n8:16:32-S128",
+			"windows": "e-m:x-p:32:32-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:32-n8:16:32-a:0:32-S32",
+		},
+	},
+	"aarch64": {
+		pointerSize: 8,
+		layout:      "e-m:%s-i8:8:32-i16:16:32-i64:64-i128:128-n32:64-S128",
+		osLayouts: map[string]string{
+			"darwin":  "e-m:o-i64:64-i128:128-n32:64-S128",
+			"windows": "e-m:w-p:64:64-i32:32-i64:64-i128:128-n32:64-S128",
+		},
+	},
+	"arm":     {pointerSize: 4, layout: "e-m:%s-p:32:32-Fi8-i64:64-v128:64:128-a:0:32-n32-S64"},
+	"riscv64": {pointerSize: 8, layout: "e-m:%s-p:64:64-i64:64-i128:128-n64-S128"},
+	"riscv32": {pointerSize: 4, layout: "e-m:%s-p:32:32-i64:64-n32-S128"},
+	"wasm32":  {pointerSize: 4, layout: "e-m:%s-p:32:32-i64:64-n32:64-S128"},
+	"wasm64":  {pointerSize: 8, layout: "e-m:%s-p:64:64-i64:64-n32:64-S128"},
+}
+
+// archAliases are other names triples use for the architectures in archLayouts
+var archAliases = map[string]string{
+	"amd64": "x86_64",
+	"i486":  "i386",
+	"i586":  "i386",
+	"i686":  "i386",
+	"arm64": "aarch64",
+	"armv6": "arm",
+	"armv7": "arm",
+}
+
+// LookupTarget returns the target described by an llvm target triple like x86_64-unknown-linux-gnu
+func LookupTarget(triple string) (Target, error) {
+	parts := strings.Split(triple, "-")
+	if len(parts) < 2 {
+		return Target{}, fmt.Errorf("invalid target triple %q, expected <arch>-<vendor>-<os>[-<env>]", triple)
+	}
+	arch := parts[0]
+	if alias, ok := archAliases[arch]; ok {
+		arch = alias
+	}
+	al, ok := archLayouts[arch]
+	if !ok {
+		return Target{}, fmt.Errorf("unsupported architecture %s in target triple %q", parts[0], triple)
+	}
+
+	sys := strings.Join(parts[1:], "-")
+	mangling := "e"
+	switch {
+	case strings.Contains(sys, "apple") || strings.Contains(sys, "darwin") || strings.Contains(sys, "macos"):
+		mangling = "o"
+		sys = "darwin"
+	case strings.Contains(sys, "windows") || strings.Contains(sys, "mingw"):
+		mangling = "w"
+		sys = "windows"
+	}
+	layout, ok := al.osLayouts[sys]
+	if !ok {
+		layout = fmt.Sprintf(al.layout, mangling)
+	}
+	return Target{Triple: triple, DataLayout: layout, PointerSize: al.pointerSize}, nil
+}
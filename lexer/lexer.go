@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"grianlang3/util"
+	"strings"
 )
 
 type Lexer struct {
@@ -12,6 +13,15 @@ type Lexer struct {
 
 	currLine uint32
 	currCh   uint32
+
+	// Comments are the comments skipped so far, in source order
+	Comments []Comment
+}
+
+// Comment is a // comment, which the parser never sees but directives like gl3:ignore are read from
+type Comment struct {
+	Text     string // without the leading //
+	Position util.Position
 }
 
 func New(input string) *Lexer {
@@ -55,6 +65,7 @@ var singleCharToken = map[byte]TokenType{
 	',': COMMA,
 	'.': DOT,
 	':': COLON,
+	'?': QUESTION,
 }
 
 func (l *Lexer) NextToken() Token {
@@ -70,17 +81,36 @@ func (l *Lexer) NextToken() Token {
 		}
 
 		if l.ch == '/' && l.peekChar() == '/' {
-			for l.ch != '\n' {
+			// the newline is left for the whitespace loop to count
+			comment := Comment{Position: util.Position{StartLine: l.currLine, StartCol: l.currCh, EndLine: l.currLine}}
+			startPos := l.pos
+			for l.ch != '\n' && l.ch != 0 {
 				l.readChar()
 			}
-			l.currLine++
-			l.currCh = 0
+			comment.Text = strings.TrimSuffix(l.input[startPos+2:l.pos], "\r")
+			comment.Position.EndCol = l.currCh
+			l.Comments = append(l.Comments, comment)
 			continue
 		}
 
 		break
 	}
 
+	if l.ch == '.' && l.peekChar() == '.' && l.readPos+1 < len(l.input) && l.input[l.readPos+1] == '.' {
+		tok.Position = util.Position{
+			StartLine: l.currLine,
+			StartCol:  l.currCh,
+			EndLine:   l.currLine,
+		}
+		l.readChar()
+		l.readChar()
+		tok.Position.EndCol = l.currCh
+		l.readChar()
+		tok.Type = ELLIPSIS
+		tok.Literal = "..."
+		return tok
+	}
+
 	sct, ok := singleCharToken[l.ch]
 	if ok {
 		tok = newToken(sct, l.ch, l.currLine, l.currCh)
@@ -264,13 +294,13 @@ func (l *Lexer) doubleCharToken(char2 byte, tt TokenType, tt2 TokenType) Token {
 	var tok Token
 
 	if l.peekChar() == char2 {
-		l.readChar()
 		tok.Position = util.Position{
 			StartLine: l.currLine,
 			StartCol:  l.currCh,
-			EndCol:    l.currLine,
+			EndLine:   l.currLine,
 		}
 		l.readChar()
+		l.readChar()
 		tok.Type = tt2
 		tok.Literal = l.input[l.pos-2 : l.pos]
 		tok.Position.EndCol = l.currCh
@@ -318,6 +348,8 @@ func identLookup(lit string) (TokenType, BaseVarType) {
 		return FALSE, None
 	case "float":
 		return TYPE, Float
+	case "va_list":
+		return TYPE, VaList
 	case "as":
 		return AS, None
 	case "sizeof":
@@ -342,6 +374,18 @@ func identLookup(lit string) (TokenType, BaseVarType) {
 		return GLOBAL, None
 	case "const":
 		return CONST, None
+	case "defer":
+		return DEFER, None
+	case "null":
+		return NULL, None
+	case "try":
+		return TRY, None
+	case "orelse":
+		return ORELSE, None
+	case "extern":
+		return EXTERN, None
+	case "export":
+		return EXPORT, None
 	}
 
 	return IDENTIFIER, None
@@ -57,6 +57,14 @@ const (
 	CONTINUE
 	GLOBAL
 	CONST
+	DEFER
+	QUESTION
+	NULL
+	TRY
+	ORELSE
+	ELLIPSIS
+	EXTERN
+	EXPORT
 	EOF
 )
 
@@ -152,6 +160,22 @@ func (tt TokenType) String() string {
 		return "."
 	case COLON:
 		return ":"
+	case DEFER:
+		return "DEFER"
+	case QUESTION:
+		return "?"
+	case NULL:
+		return "NULL"
+	case TRY:
+		return "TRY"
+	case ORELSE:
+		return "ORELSE"
+	case ELLIPSIS:
+		return "..."
+	case EXTERN:
+		return "EXTERN"
+	case EXPORT:
+		return "EXPORT"
 	default:
 		return "UNKNOWN"
 	}
@@ -163,6 +187,12 @@ type VarType struct {
 	// if true ignore base, use StructName
 	IsStructType bool
 	StructName   string
+	// set for functions with multiple return values, ignore everything else
+	Tuple []VarType
+	// Optional wraps the type described by the rest of the fields into ?T
+	Optional bool
+	// ErrType is set for result types T!E, T being described by the rest of the fields
+	ErrType *VarType
 }
 
 type BaseVarType uint8
@@ -181,6 +211,7 @@ const (
 	Bool
 	Void
 	Float
+	VaList
 )
 
 func (bvt BaseVarType) String() string {
@@ -211,6 +242,8 @@ func (bvt BaseVarType) String() string {
 		return "Bool"
 	case Float:
 		return "Float"
+	case VaList:
+		return "VaList"
 	default:
 		return "Unknown"
 	}
@@ -218,6 +251,20 @@ func (bvt BaseVarType) String() string {
 
 func (vt VarType) String() string {
 	var bvt strings.Builder
+	if vt.Tuple != nil {
+		bvt.WriteString("(")
+		for i, t := range vt.Tuple {
+			bvt.WriteString(t.String())
+			if i != len(vt.Tuple)-1 {
+				bvt.WriteString(", ")
+			}
+		}
+		bvt.WriteString(")")
+		return bvt.String()
+	}
+	if vt.Optional {
+		bvt.WriteString("?")
+	}
 	if vt.IsStructType {
 		bvt.WriteString(vt.StructName)
 	} else {
@@ -228,6 +275,11 @@ func (vt VarType) String() string {
 		bvt.WriteString("*")
 	}
 
+	if vt.ErrType != nil {
+		bvt.WriteString("!")
+		bvt.WriteString(vt.ErrType.String())
+	}
+
 	return bvt.String()
 }
 
@@ -237,3 +289,34 @@ type Token struct {
 	Literal  string
 	Position util.Position
 }
+
+// Equals is needed over == as tuple types can't be compared directly
+func (vt VarType) Equals(other VarType) bool {
+	if len(vt.Tuple) != len(other.Tuple) {
+		return false
+	}
+	for i := range vt.Tuple {
+		if !vt.Tuple[i].Equals(other.Tuple[i]) {
+			return false
+		}
+	}
+
+	if (vt.ErrType == nil) != (other.ErrType == nil) || (vt.ErrType != nil && !vt.ErrType.Equals(*other.ErrType)) {
+		return false
+	}
+
+	return vt.Base == other.Base && vt.Pointer == other.Pointer && vt.IsStructType == other.IsStructType &&
+		vt.StructName == other.StructName && vt.Optional == other.Optional
+}
+
+// IsWrapped reports whether the type is an optional or a result, and so has to be unwrapped before use
+func (vt VarType) IsWrapped() bool {
+	return vt.Optional || vt.ErrType != nil
+}
+
+// Unwrapped returns T for ?T and T!E
+func (vt VarType) Unwrapped() VarType {
+	vt.Optional = false
+	vt.ErrType = nil
+	return vt
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"embed"
+	"errors"
 	"grianlang3/cli"
 	"os"
 
@@ -10,7 +11,7 @@ import (
 	"github.com/spf13/cobra"
 )
 
-//go:embed builtins/*.ll
+//go:embed builtins/*.ll builtins/*.c
 var builtinFs embed.FS
 
 func main() {
@@ -24,37 +25,119 @@ func main() {
 
 	var buildOpts cli.BuildOpts
 	buildCmd := &cobra.Command{
-		Use:   "build",
+		Use:   "build <files...>",
 		Short: "Compile gl3 files to an executable",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			err := cli.RunBuildCmd(builtinFs, args, &buildOpts)
-			if err != nil {
-				if err := os.RemoveAll("./lltemp"); err != nil {
-					return err
-				}
+			return cli.RunBuildCmd(builtinFs, args, &buildOpts)
+		},
+	}
+	buildCmd.Flags().BoolVar(&buildOpts.Dbg, "dbg", false, "Prints out the AST for all compiled files, along with the `clang` command used for compilation")
+	buildCmd.Flags().BoolVar(&buildOpts.KeepLL, "keepll", false, "Keeps the temporary directory holding the .ll files produced by compilation, printing its path")
+	buildCmd.Flags().BoolVar(&buildOpts.NoExecBuild, "noexecbuild", false, "Does not execute the `clang` build command")
+	buildCmd.Flags().StringVarP(&buildOpts.Output, "output", "o", "", "Path of the output, - for stdout, defaults to out for executables and the input's name otherwise")
+	buildCmd.Flags().StringVar(&buildOpts.Emit, "emit", "exe", "What to output: ast, ll, bc, asm, obj, exe, staticlib or sharedlib")
+	buildCmd.Flags().StringVar(&buildOpts.Target, "target", "", "LLVM target triple to build for, like aarch64-unknown-linux-gnu, defaults to the host")
+	buildCmd.Flags().StringVarP(&buildOpts.OptLevel, "opt-level", "O", "0", "Optimization level: -O0, -O1, -O2, -O3 or -Os for size")
+	buildCmd.Flags().StringSliceVarP(&buildOpts.Libs, "lib", "l", nil, "Links against the given library, for C functions declared with `extern`")
+	buildCmd.Flags().StringSliceVarP(&buildOpts.LibDirs, "libdir", "L", nil, "Adds a directory to the library search path")
+	buildCmd.Flags().StringVar(&buildOpts.DiagnosticsFormat, "diagnostics-format", "text", "Format of errors and warnings, `text` on stderr, or json or sarif on stdout for tools")
+	buildCmd.Flags().StringArrayVarP(&buildOpts.Warnings, "warn", "W", nil, "Controls warnings: -Werror, -Werror=<name>, -W<name> or -Wno-<name>, names are listed by gl3 explain")
+
+	var runOpts cli.BuildOpts
+	runCmd := &cobra.Command{
+		Use:   "run <files...> [-- args...]",
+		Short: "Compile gl3 files and run the executable, passing it the arguments after --",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files, progArgs := args, []string(nil)
+			if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+				files, progArgs = args[:dash], args[dash:]
+			}
+			err := cli.RunRunCmd(builtinFs, files, progArgs, &runOpts)
+			// the program has had its say, exit with its code rather than reporting an error on top
+			var exitErr *cli.ExitError
+			if errors.As(err, &exitErr) {
+				os.Exit(exitErr.Code)
 			}
 			return err
 		},
 	}
-	buildCmd.Flags().BoolVar(&buildOpts.Dbg, "dbg", false, "Prints out the AST for all compiled files, along with the `clang` command used for compilation")
-	buildCmd.Flags().BoolVar(&buildOpts.Dbg, "keepll", false, "Saves the .ll files produced by compilation")
-	buildCmd.Flags().BoolVar(&buildOpts.Dbg, "noexecbuild", false, "Does not execute the `clang` build command")
+	runCmd.Flags().StringVarP(&runOpts.OptLevel, "opt-level", "O", "0", "Optimization level: -O0, -O1, -O2, -O3 or -Os for size")
+	runCmd.Flags().StringSliceVarP(&runOpts.Libs, "lib", "l", nil, "Links against the given library, for C functions declared with `extern`")
+	runCmd.Flags().StringSliceVarP(&runOpts.LibDirs, "libdir", "L", nil, "Adds a directory to the library search path")
+	runCmd.Flags().StringVar(&runOpts.DiagnosticsFormat, "diagnostics-format", "text", "Format of errors and warnings, `text` on stderr, or json or sarif on stdout for tools")
+	runCmd.Flags().StringArrayVarP(&runOpts.Warnings, "warn", "W", nil, "Controls warnings: -Werror, -Werror=<name>, -W<name> or -Wno-<name>, names are listed by gl3 explain")
+
+	var checkOpts cli.CheckOpts
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Reports errors and warnings in gl3 files without building them",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.RunCheckCmd(args, &checkOpts)
+		},
+	}
+	checkCmd.Flags().StringVar(&checkOpts.DiagnosticsFormat, "diagnostics-format", "text", "Format of errors and warnings, `text` on stderr, or json or sarif on stdout for tools")
+	checkCmd.Flags().StringArrayVarP(&checkOpts.Warnings, "warn", "W", nil, "Controls warnings: -Werror, -Werror=<name>, -W<name> or -Wno-<name>, names are listed by gl3 explain")
+	checkCmd.Flags().BoolVar(&checkOpts.Codegen, "codegen", false, "Also generates LLVM IR in memory, catching the errors only code generation finds")
 
 	var exDefOpts cli.ExDefOpts
 	exDefCmd := &cobra.Command{
-		Use:   "exdef",
-		Short: "Extracts define statements from a C header file and outputs a .gl3 file defining them as constants",
+		Use:   "exdef [headers...]",
+		Short: "Extracts define statements from C header files and outputs a .gl3 file defining them as constants",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			exDefOpts.InFiles = append(exDefOpts.InFiles, args...)
 			return cli.RunExDef(&exDefOpts)
 		},
 	}
-	exDefCmd.Flags().StringVarP(&exDefOpts.InFile, "input", "i", "", "Path to the input header")
-	exDefCmd.Flags().StringVarP(&exDefOpts.OutFile, "output", "o", "", "Path to the output .gl3 file")
-	exDefCmd.MarkFlagRequired("input")
+	exDefCmd.Flags().StringArrayVarP(&exDefOpts.InFiles, "input", "i", nil, "Path to an input header, as well as or instead of the arguments")
+	exDefCmd.Flags().StringVarP(&exDefOpts.OutFile, "output", "o", "", "Path to the output .gl3 file, - for stdout")
+	exDefCmd.Flags().StringArrayVarP(&exDefOpts.IncludeDirs, "include", "I", nil, "Adds a directory to the header search path")
+	exDefCmd.Flags().StringArrayVarP(&exDefOpts.Defines, "define", "D", nil, "Defines a macro before reading the headers, as NAME or NAME=VALUE")
+	exDefCmd.Flags().StringArrayVar(&exDefOpts.Prefixes, "prefix", nil, "Only outputs the defines starting with this prefix, can be given more than once")
+	exDefCmd.Flags().StringVar(&exDefOpts.Match, "match", "", "Only outputs the defines whose names match this regular expression")
+	exDefCmd.Flags().BoolVarP(&exDefOpts.Force, "force", "f", false, "Overwrites the output file if it exists")
 	exDefCmd.MarkFlagRequired("output")
 
+	var cHeaderOpts cli.CHeaderOpts
+	cHeaderCmd := &cobra.Command{
+		Use:   "cheader <files...>",
+		Short: "Writes a C header declaring the exported functions, structs and global constants of gl3 files",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.RunCHeaderCmd(args, &cHeaderOpts)
+		},
+	}
+	cHeaderCmd.Flags().StringVarP(&cHeaderOpts.Output, "output", "o", "", "Path of the header, - for stdout, defaults to the first input's name with .h")
+	cHeaderCmd.Flags().StringVar(&cHeaderOpts.DiagnosticsFormat, "diagnostics-format", "text", "Format of errors and warnings, `text` on stderr, or json or sarif on stdout for tools")
+
+	var bindgenOpts cli.BindgenOpts
+	bindgenCmd := &cobra.Command{
+		Use:   "bindgen <header>",
+		Short: "Generates gl3 extern declarations for the functions, structs and enums of a C header",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.RunBindgenCmd(args[0], &bindgenOpts)
+		},
+	}
+	bindgenCmd.Flags().StringVarP(&bindgenOpts.Output, "output", "o", "", "Path of the .gl3 file, - for stdout, defaults to the header's name with .gl3")
+
+	explainCmd := &cobra.Command{
+		Use:   "explain [code]",
+		Short: "Explains a diagnostic code like E0012, or lists them all",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cli.RunExplainCmd(cmd.OutOrStdout(), args)
+		},
+	}
+
 	rootCmd.AddCommand(buildCmd)
+	rootCmd.AddCommand(checkCmd)
+	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(exDefCmd)
+	rootCmd.AddCommand(cHeaderCmd)
+	rootCmd.AddCommand(bindgenCmd)
+	rootCmd.AddCommand(explainCmd)
 
 	if err := fang.Execute(
 		context.Background(),
@@ -148,12 +148,16 @@ type DefStatement struct {
 	Right    Expression
 	Global   bool
 	Constant bool
+	Extern   bool // extern globals have no Right
 }
 
 func (ds *DefStatement) statementNode()       { /* noop */ }
 func (ds *DefStatement) TokenLiteral() string { return ds.Token.Literal }
 func (ds *DefStatement) String() string {
 	var str strings.Builder
+	if ds.Extern {
+		str.WriteString("extern ")
+	}
 	if ds.Global {
 		str.WriteString("global ")
 		if ds.Constant {
@@ -166,14 +170,21 @@ func (ds *DefStatement) String() string {
 	str.WriteString(ds.Type.String())
 	str.WriteRune(' ')
 	str.WriteString(ds.Name.String())
-	str.WriteString(" = ")
-	str.WriteString(ds.Right.String())
+	if ds.Right != nil {
+		str.WriteString(" = ")
+		str.WriteString(ds.Right.String())
+	}
 
 	return str.String()
 }
 func (ds *DefStatement) Position() *util.Position {
 	tokenPos := ds.Token.Position
-	rightPos := ds.Right.Position()
+	var rightPos *util.Position
+	if ds.Right != nil {
+		rightPos = ds.Right.Position()
+	} else {
+		rightPos = ds.Name.Position()
+	}
 
 	return &util.Position{
 		StartLine: tokenPos.StartLine,
@@ -222,8 +233,16 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       { /* noop */ }
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
-func (rs *ReturnStatement) String() string       { return "return " + rs.Expr.String() }
+func (rs *ReturnStatement) String() string {
+	if rs.Expr == nil {
+		return "return"
+	}
+	return "return " + rs.Expr.String()
+}
 func (rs *ReturnStatement) Position() *util.Position {
+	if rs.Expr == nil {
+		return &rs.Token.Position
+	}
 	tokPos := rs.Token.Position
 	exprPos := rs.Expr.Position()
 	return &util.Position{
@@ -271,6 +290,9 @@ type FunctionStatement struct {
 	Name     *IdentifierExpression
 	Type     lexer.VarType
 	Params   []FunctionParameter
+	Variadic bool // trailing ... parameter
+	Extern   bool // extern functions have no Body
+	Exported bool // visible outside the library the function is built into
 	Body     *BlockStatement
 	position util.Position
 }
@@ -280,6 +302,12 @@ func (fs *FunctionStatement) TokenLiteral() string { return fs.Token.Literal }
 func (fs *FunctionStatement) String() string {
 	var out bytes.Buffer
 
+	if fs.Extern {
+		out.WriteString("extern ")
+	}
+	if fs.Exported {
+		out.WriteString("export ")
+	}
 	out.WriteString("fnc " + fs.Name.String() + "(")
 
 	for i, p := range fs.Params {
@@ -288,7 +316,16 @@ func (fs *FunctionStatement) String() string {
 			out.WriteString(", ")
 		}
 	}
-	out.WriteString(") -> " + fs.Type.String() + " { " + fs.Body.String() + " }")
+	if fs.Variadic {
+		if len(fs.Params) > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString("...")
+	}
+	out.WriteString(") -> " + fs.Type.String())
+	if fs.Body != nil {
+		out.WriteString(" { " + fs.Body.String() + " }")
+	}
 	return out.String()
 }
 func (fs *FunctionStatement) Position() *util.Position {
@@ -332,6 +369,9 @@ func (re *ReferenceExpression) expressionNode()      { /* noop */ }
 func (re *ReferenceExpression) TokenLiteral() string { return re.Token.Literal }
 func (re *ReferenceExpression) String() string       { return "&" + re.Var.String() }
 func (re *ReferenceExpression) Position() *util.Position {
+	if re.Var == nil {
+		return &re.Token.Position
+	}
 	vPos := re.Var.Position()
 	return &util.Position{
 		StartLine: re.Token.Position.StartLine,
@@ -381,6 +421,9 @@ func (pe *PrefixExpression) expressionNode()      { /* noop */ }
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
 func (pe *PrefixExpression) String() string       { return "(" + pe.Operator + pe.Right.String() + ")" }
 func (pe *PrefixExpression) Position() *util.Position {
+	if pe.Right == nil {
+		return &pe.Token.Position
+	}
 	rPos := pe.Right.Position()
 	return &util.Position{
 		StartLine: pe.Token.Position.StartLine,
@@ -619,3 +662,131 @@ func (c *ContinueStatement) statementNode()           { /* noop */ }
 func (c *ContinueStatement) TokenLiteral() string     { return c.Token.Literal }
 func (c *ContinueStatement) String() string           { return c.Token.Literal }
 func (c *ContinueStatement) Position() *util.Position { return &c.Token.Position }
+
+type DeferStatement struct {
+	Token lexer.Token
+	Expr  Expression
+}
+
+func (ds *DeferStatement) statementNode()       { /* noop */ }
+func (ds *DeferStatement) TokenLiteral() string { return ds.Token.Literal }
+func (ds *DeferStatement) String() string       { return "defer " + ds.Expr.String() }
+func (ds *DeferStatement) Position() *util.Position {
+	exprPos := ds.Expr.Position()
+	return &util.Position{
+		StartLine: ds.Token.Position.StartLine,
+		StartCol:  ds.Token.Position.StartCol,
+		EndLine:   exprPos.EndLine,
+		EndCol:    exprPos.EndCol,
+	}
+}
+
+// TupleExpression is the value list of a return statement in a function with multiple return values
+type TupleExpression struct {
+	Token lexer.Token
+	Items []Expression
+}
+
+func (te *TupleExpression) expressionNode()      { /* noop */ }
+func (te *TupleExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TupleExpression) String() string {
+	var out bytes.Buffer
+	for i, e := range te.Items {
+		out.WriteString(e.String())
+		if i != len(te.Items)-1 {
+			out.WriteString(", ")
+		}
+	}
+	return out.String()
+}
+func (te *TupleExpression) Position() *util.Position {
+	firstPos := te.Items[0].Position()
+	lastPos := te.Items[len(te.Items)-1].Position()
+	return &util.Position{
+		StartLine: firstPos.StartLine,
+		StartCol:  firstPos.StartCol,
+		EndLine:   lastPos.EndLine,
+		EndCol:    lastPos.EndCol,
+	}
+}
+
+type DestructureStatement struct {
+	Token lexer.Token
+	Names []*IdentifierExpression
+	Types []lexer.VarType
+	Right Expression
+}
+
+func (ds *DestructureStatement) statementNode()       { /* noop */ }
+func (ds *DestructureStatement) TokenLiteral() string { return ds.Token.Literal }
+func (ds *DestructureStatement) String() string {
+	var str strings.Builder
+	str.WriteString("def ")
+	for i, name := range ds.Names {
+		str.WriteString(ds.Types[i].String())
+		str.WriteRune(' ')
+		str.WriteString(name.String())
+		if i != len(ds.Names)-1 {
+			str.WriteString(", ")
+		}
+	}
+	str.WriteString(" = ")
+	str.WriteString(ds.Right.String())
+
+	return str.String()
+}
+func (ds *DestructureStatement) Position() *util.Position {
+	rightPos := ds.Right.Position()
+	return &util.Position{
+		StartLine: ds.Token.Position.StartLine,
+		StartCol:  ds.Token.Position.StartCol,
+		EndLine:   rightPos.EndLine,
+		EndCol:    rightPos.EndCol,
+	}
+}
+
+type NullLiteral struct {
+	Token lexer.Token
+}
+
+func (nl *NullLiteral) expressionNode()          { /* noop */ }
+func (nl *NullLiteral) TokenLiteral() string     { return nl.Token.Literal }
+func (nl *NullLiteral) String() string           { return "null" }
+func (nl *NullLiteral) Position() *util.Position { return &nl.Token.Position }
+
+// WrapExpression is one of some(x), ok(x) or err(x), the kind being the token literal. the optional or result type it
+// produces is taken from where it's used
+type WrapExpression struct {
+	Token    lexer.Token
+	Value    Expression
+	position util.Position
+}
+
+func (we *WrapExpression) expressionNode()      { /* noop */ }
+func (we *WrapExpression) TokenLiteral() string { return we.Token.Literal }
+func (we *WrapExpression) String() string {
+	return we.Token.Literal + "(" + we.Value.String() + ")"
+}
+func (we *WrapExpression) Position() *util.Position {
+	return &we.position
+}
+
+// TryExpression unwraps an optional or result, returning early from the enclosing function if it is empty or an error
+type TryExpression struct {
+	Token    lexer.Token
+	Expr     Expression
+	Postfix  bool
+	position util.Position
+}
+
+func (te *TryExpression) expressionNode()      { /* noop */ }
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) String() string {
+	if te.Postfix {
+		return te.Expr.String() + "?"
+	}
+	return "(try " + te.Expr.String() + ")"
+}
+func (te *TryExpression) Position() *util.Position {
+	return &te.position
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"grianlang3/diagnostics"
 	"grianlang3/lexer"
 	"grianlang3/util"
 	"strconv"
@@ -11,6 +12,7 @@ const (
 	_ byte = iota
 	LOWEST
 	ASSIGN
+	ORELSE
 	LOR
 	LAND
 	EQUALS      // ==
@@ -43,6 +45,14 @@ var precedences = map[lexer.TokenType]byte{
 	lexer.LTEQ:     LESSGREATER,
 	lexer.LBRACKET: INDEX,
 	lexer.AS:       CAST,
+	lexer.QUESTION: CALL,
+	lexer.ORELSE:   ORELSE,
+}
+
+var wrapConstructors = map[string]struct{}{
+	"some": {},
+	"ok":   {},
+	"err":  {},
 }
 
 type (
@@ -59,6 +69,13 @@ type Parser struct {
 	currToken lexer.Token
 	peekToken lexer.Token
 
+	// braceDepth counts the { consumed without their }, so recovery can find the end of a broken statement's blocks
+	braceDepth int
+	// panicking is set by the first error in a statement and cleared once the parser has skipped to the start of the
+	// next one, anything reported in between would be a cascade of that first error
+	panicking bool
+	panicLine uint32
+
 	prefixParseFns map[lexer.TokenType]prefixParseFn
 	infixParseFns  map[lexer.TokenType]infixParseFn
 }
@@ -84,6 +101,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns[lexer.SIZEOF] = p.parseSizeofExpression
 	p.prefixParseFns[lexer.LBRACKET] = p.parseArrayLiteral
 	p.prefixParseFns[lexer.CHAR] = p.parseCharLiteral
+	p.prefixParseFns[lexer.NULL] = p.parseNullLiteral
+	p.prefixParseFns[lexer.TRY] = p.parseTryExpression
 
 	p.infixParseFns = make(map[lexer.TokenType]infixParseFn)
 	p.infixParseFns[lexer.PLUS] = p.parseInfixExpression
@@ -104,11 +123,19 @@ func New(l *lexer.Lexer) *Parser {
 	p.infixParseFns[lexer.AS] = p.parseCastExpression
 	p.infixParseFns[lexer.LBRACKET] = p.parseArrayIndexExpression
 	p.infixParseFns[lexer.COLON] = p.parseStructInitialization
+	p.infixParseFns[lexer.QUESTION] = p.parsePostfixTryExpression
+	p.infixParseFns[lexer.ORELSE] = p.parseInfixExpression
 
 	return p
 }
 
 func (p *Parser) NextToken() {
+	switch p.currToken.Type {
+	case lexer.LBRACE:
+		p.braceDepth++
+	case lexer.RBRACE:
+		p.braceDepth--
+	}
 	p.currToken = p.peekToken
 	p.peekToken = p.lexer.NextToken()
 }
@@ -141,6 +168,10 @@ func (p *Parser) parsePrefixExpression() Expression {
 }
 
 func (p *Parser) parseIdentifier() Expression {
+	// contextual rather than keywords, as ok and err are far too common as variable names
+	if _, ok := wrapConstructors[p.currToken.Literal]; ok && p.peekTokenIs(lexer.LPAREN) {
+		return p.parseWrapExpression()
+	}
 	expr := &IdentifierExpression{Token: p.currToken, Value: p.currToken.Literal}
 	p.NextToken()
 	return expr
@@ -162,22 +193,10 @@ func (p *Parser) parseIntegerLiteral() Expression {
 	vt := lexer.VarType{Base: lexer.Int, Pointer: 0}
 	lit := &IntegerLiteral{Token: p.currToken, Type: vt}
 
-	// could be more efficient by parsing based on type or the lack thereof but this makes for a decent chunk cleaner
-	// code
-	value, err := strconv.ParseInt(p.currToken.Literal, 0, 64)
-	if err != nil {
-		p.appendError(&p.currToken.Position, "could not parse %q as integer", p.currToken.Literal)
-	}
-	uvalue, err := strconv.ParseUint(p.currToken.Literal, 0, 64)
-	if err != nil {
-		p.appendError(&p.currToken.Position, "could not parse %q as unsigned integer", p.currToken.Literal)
-	}
-
-	lit.Value = value
-	lit.UValue = uvalue
-
 	p.NextToken()
-	if p.currTokenIs(lexer.IDENTIFIER) {
+	// a suffix is written right after the digits, anything further away is the next expression or statement
+	if p.currTokenIs(lexer.IDENTIFIER) && p.currToken.Position.StartLine == lit.Token.Position.EndLine &&
+		p.currToken.Position.StartCol == lit.Token.Position.EndCol {
 		switch p.currToken.Literal {
 		case "i32":
 			lit.Type.Base = lexer.Int32
@@ -197,6 +216,24 @@ func (p *Parser) parseIntegerLiteral() Expression {
 		p.NextToken()
 	}
 
+	// parsed by the signedness of the suffix, so unsigned literals can go past the largest signed value
+	switch lit.Type.Base {
+	case lexer.Uint8, lexer.Uint16, lexer.Uint32, lexer.Uint:
+		uvalue, err := strconv.ParseUint(lit.Token.Literal, 0, 64)
+		if err != nil {
+			p.appendError(&lit.Token.Position, diagnostics.CodeBadLiteral, "could not parse %q as unsigned integer", lit.Token.Literal)
+		}
+		lit.Value = int64(uvalue)
+		lit.UValue = uvalue
+	default:
+		value, err := strconv.ParseInt(lit.Token.Literal, 0, 64)
+		if err != nil {
+			p.appendError(&lit.Token.Position, diagnostics.CodeBadLiteral, "could not parse %q as integer", lit.Token.Literal)
+		}
+		lit.Value = value
+		lit.UValue = uint64(value)
+	}
+
 	return lit
 }
 
@@ -211,20 +248,11 @@ func (p *Parser) parseSizeofExpression() Expression {
 		StartLine: p.currToken.Position.StartLine,
 		StartCol:  p.currToken.Position.StartCol,
 	}}
-	var vt lexer.VarType
 	p.NextToken() // past sizeof
-	if p.currTokenIs(lexer.TYPE) {
-		vt = p.currToken.VarType
-	} else if p.currTokenIs(lexer.IDENTIFIER) {
-		vt = lexer.VarType{
-			IsStructType: true,
-			StructName:   p.currToken.Literal,
-		}
-	} else {
+	vt, ok := p.parseVarType()
+	if !ok {
 		return nil
 	}
-	p.NextToken() // past type/ident
-	p.getPointers(&vt)
 
 	expr.Type = vt
 	expr.Position().EndLine = p.currToken.Position.EndLine
@@ -239,7 +267,7 @@ func (p *Parser) parseFloatLiteral() Expression {
 
 	value, err := strconv.ParseFloat(p.currToken.Literal, 32)
 	if err != nil {
-		p.appendError(&p.currToken.Position, "could not parse %q as float", p.currToken.Literal)
+		p.appendError(&p.currToken.Position, diagnostics.CodeBadLiteral, "could not parse %q as float", p.currToken.Literal)
 	}
 
 	lit.Value = float32(value)
@@ -263,19 +291,11 @@ func (p *Parser) parseCastExpression(left Expression) Expression {
 	expr.Expr = left
 
 	p.NextToken() // asvance past AS
-	var castType lexer.VarType
-	if p.currTokenIs(lexer.TYPE) {
-		castType = p.currToken.VarType
-	} else if p.currTokenIs(lexer.IDENTIFIER) {
-		castType = lexer.VarType{
-			IsStructType: true,
-			StructName:   p.currToken.Literal,
-		}
-	} else {
+	castType, ok := p.parseVarType()
+	if !ok {
+		p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected type after as, got %s", p.currToken.Type)
 		return nil
 	}
-	p.NextToken() // advance past type/ident
-	p.getPointers(&castType)
 	expr.Type = castType
 	expr.Position().CopyEnd(&p.currToken.Position)
 
@@ -287,7 +307,7 @@ func (p *Parser) parseStructInitialization(left Expression) Expression {
 	if ident, ok := left.(*IdentifierExpression); ok {
 		exp.Name = ident.Value
 	} else {
-		p.appendError(&p.currToken.Position, "expected identifier on lhs of struct init")
+		p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected identifier on lhs of struct init")
 		return nil
 	}
 	p.NextToken() // skip past :
@@ -304,6 +324,7 @@ func (p *Parser) parseStructInitialization(left Expression) Expression {
 			p.NextToken()
 			continue
 		} else {
+			p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected , or } in struct initialization, got %s", p.currToken.Type)
 			return nil
 		}
 	}
@@ -312,6 +333,58 @@ func (p *Parser) parseStructInitialization(left Expression) Expression {
 	return exp
 }
 
+func (p *Parser) parseNullLiteral() Expression {
+	expr := &NullLiteral{Token: p.currToken}
+	p.NextToken()
+	return expr
+}
+
+// parseWrapExpression parses the some(x), ok(x) and err(x) constructors for optionals and results
+func (p *Parser) parseWrapExpression() Expression {
+	expr := &WrapExpression{Token: p.currToken, position: util.Position{
+		StartLine: p.currToken.Position.StartLine,
+		StartCol:  p.currToken.Position.StartCol,
+	}}
+	p.NextToken()
+	if !p.expectCurr(lexer.LPAREN) {
+		return nil
+	}
+	expr.Value = p.parseExpression(LOWEST)
+	if expr.Value == nil {
+		return nil
+	}
+	expr.position.CopyEnd(&p.currToken.Position)
+	if !p.expectCurr(lexer.RPAREN) {
+		return nil
+	}
+
+	return expr
+}
+
+func (p *Parser) parseTryExpression() Expression {
+	expr := &TryExpression{Token: p.currToken}
+	p.NextToken() // past try
+	expr.Expr = p.parseExpression(PREFIX)
+	if expr.Expr == nil {
+		return nil
+	}
+	expr.position = *expr.Expr.Position()
+	expr.position.StartLine = expr.Token.Position.StartLine
+	expr.position.StartCol = expr.Token.Position.StartCol
+
+	return expr
+}
+
+// parsePostfixTryExpression is the x? form of try x
+func (p *Parser) parsePostfixTryExpression(left Expression) Expression {
+	expr := &TryExpression{Token: p.currToken, Expr: left, Postfix: true}
+	expr.position = *left.Position()
+	expr.position.CopyEnd(&p.currToken.Position)
+	p.NextToken() // past ?
+
+	return expr
+}
+
 func (p *Parser) parseBoolean() Expression {
 	expr := &BooleanExpression{Token: p.currToken}
 
@@ -344,7 +417,7 @@ func (p *Parser) parseAssignExpression(left Expression) Expression {
 		if infix, ok := left.(*InfixExpression); ok && infix.Operator == "." {
 			expr.Left = left
 		} else {
-			p.appendError(left.Position(), "got %T on lhs of assignment, expected ident or deref", left)
+			p.appendError(left.Position(), diagnostics.CodeBadAssignTarget, "got %T on lhs of assignment, expected ident or deref", left)
 		}
 	}
 	p.NextToken()
@@ -375,6 +448,7 @@ func (p *Parser) parseArrayIndexExpression(left Expression) Expression {
 	p.NextToken() // skip past [
 	index := p.parseExpression(LOWEST)
 	if !p.currTokenIs(lexer.RBRACKET) {
+		p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected ] after index, got %s", p.currToken.Type)
 		return nil
 	}
 	p.NextToken()
@@ -408,6 +482,7 @@ func (p *Parser) parseArrayLiteral() Expression {
 			p.NextToken()
 			continue
 		} else {
+			p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected , or ] in array literal, got %s", p.currToken.Type)
 			return nil
 		}
 	}
@@ -421,26 +496,75 @@ func (p *Parser) ParseProgram() *Program {
 	program.Statements = []Statement{}
 
 	for !p.currTokenIs(lexer.EOF) {
+		depth := p.braceDepth
 		stmt := p.parseStatement()
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
+		if p.panicking || stmt == nil {
+			p.synchronize(depth)
+			// nothing at the top level starts with }, it's what's left of whatever failed to parse
+			if p.currTokenIs(lexer.RBRACE) {
+				p.NextToken()
+			}
+			continue
 		}
+		program.Statements = append(program.Statements, stmt)
 	}
 
 	return program
 }
 
+// statementStarts are the tokens synchronize stops at, those that can only begin a statement
+var statementStarts = map[lexer.TokenType]struct{}{
+	lexer.DEF:      {},
+	lexer.GLOBAL:   {},
+	lexer.FNC:      {},
+	lexer.STRUCT:   {},
+	lexer.IMPORT:   {},
+	lexer.EXTERN:   {},
+	lexer.EXPORT:   {},
+	lexer.RETURN:   {},
+	lexer.IF:       {},
+	lexer.WHILE:    {},
+	lexer.BREAK:    {},
+	lexer.CONTINUE: {},
+	lexer.DEFER:    {},
+}
+
+// synchronize skips the rest of a statement that failed to parse. any blocks it opened are skipped whole, then it
+// stops at the first statement keyword, ; or } or token on a later line than the error, depth being the brace depth
+// the statement started at
+func (p *Parser) synchronize(depth int) {
+	for !p.currTokenIs(lexer.EOF) {
+		if p.braceDepth <= depth {
+			if p.currTokenIs(lexer.RBRACE) {
+				break
+			}
+			if p.currTokenIs(lexer.SEMICOLON) {
+				p.NextToken()
+				break
+			}
+			if _, ok := statementStarts[p.currToken.Type]; ok {
+				break
+			}
+			if p.currToken.Position.StartLine > p.panicLine && !p.currTokenIs(lexer.LBRACE) {
+				break
+			}
+		}
+		p.NextToken()
+	}
+	p.panicking = false
+}
+
 func (p *Parser) parseCallExpression(left Expression) Expression {
-	leftPos := left.Position()
-	exp := &CallExpression{Token: p.currToken, position: util.Position{
-		StartLine: leftPos.StartLine,
-		StartCol:  leftPos.StartCol,
-	}}
-	if identExpr, ok := left.(*IdentifierExpression); ok {
-		exp.Function = identExpr
-	} else {
+	identExpr, ok := left.(*IdentifierExpression)
+	if !ok {
+		// left may be nil after an error, so its position can't be relied on
+		p.appendError(&p.currToken.Position, diagnostics.CodeBadCallTarget, "can only call functions by name, got %T", left)
 		return nil
 	}
+	exp := &CallExpression{Token: p.currToken, Function: identExpr, position: util.Position{
+		StartLine: identExpr.Token.Position.StartLine,
+		StartCol:  identExpr.Token.Position.StartCol,
+	}}
 	if !p.expectCurr(lexer.LPAREN) {
 		return nil
 	}
@@ -459,6 +583,7 @@ func (p *Parser) parseCallExpression(left Expression) Expression {
 			p.NextToken()
 			continue
 		} else {
+			p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected , or ) in call arguments, got %s", p.currToken.Type)
 			return nil
 		}
 	}
@@ -488,6 +613,12 @@ func (p *Parser) parseStatement() Statement {
 		return p.parseBreakStatement()
 	case lexer.CONTINUE:
 		return p.parseContinueStatement()
+	case lexer.DEFER:
+		return p.parseDeferStatement()
+	case lexer.EXTERN:
+		return p.parseExternStatement()
+	case lexer.EXPORT:
+		return p.parseExportStatement()
 	}
 
 	return p.parseExpressionStatement()
@@ -500,7 +631,7 @@ func (p *Parser) parseStructStatement() Statement {
 	}}
 	p.NextToken()
 	if !p.currTokenIs(lexer.IDENTIFIER) {
-		p.appendError(&p.currToken.Position, "expected identifier after struct keyword")
+		p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected identifier after struct keyword")
 		return nil
 	}
 	stmt.Name = p.currToken.Literal
@@ -510,29 +641,18 @@ func (p *Parser) parseStructStatement() Statement {
 	}
 	stmt.Names = make(map[string]int)
 	for !p.currTokenIs(lexer.RBRACE) {
-		var vt lexer.VarType
-
-		if p.currTokenIs(lexer.TYPE) {
-			vt = p.currToken.VarType
-			p.NextToken()
-		} else if p.currTokenIs(lexer.IDENTIFIER) {
-			vt = lexer.VarType{
-				IsStructType: true,
-				StructName:   p.currToken.Literal,
-			}
-			p.NextToken()
-		} else {
+		vt, ok := p.parseVarType()
+		if !ok {
 			pos := stmt.Position()
 			pos.CopyEnd(&p.currToken.Position)
-			p.appendError(pos, "expected type in struct definition")
+			p.appendError(pos, diagnostics.CodeSyntax, "expected type in struct definition")
 			return nil
 		}
-		p.getPointers(&vt)
 
 		if !p.currTokenIs(lexer.IDENTIFIER) {
 			pos := stmt.Position()
 			pos.CopyEnd(&p.currToken.Position)
-			p.appendError(pos, "expected identifier after type in struct definition")
+			p.appendError(pos, diagnostics.CodeSyntax, "expected identifier after type in struct definition")
 			return nil
 		}
 		stmt.Types = append(stmt.Types, vt)
@@ -609,6 +729,7 @@ func (p *Parser) parseImportStatement() Statement {
 	}}
 	p.NextToken()
 	if !p.currTokenIs(lexer.STRING) {
+		p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected string path after import keyword, got %s", p.currToken.Type)
 		return nil
 	}
 	stmt.Path = p.currToken.Literal
@@ -622,7 +743,19 @@ func (p *Parser) parseImportStatement() Statement {
 func (p *Parser) parseReturnStatement() Statement {
 	stmt := &ReturnStatement{Token: p.currToken}
 	p.NextToken()
+	// bare return from a none function
+	if p.currTokenIs(lexer.RBRACE) || p.currTokenIs(lexer.SEMICOLON) {
+		return stmt
+	}
 	expr := p.parseExpression(LOWEST)
+	if p.currTokenIs(lexer.COMMA) {
+		tuple := &TupleExpression{Token: p.currToken, Items: []Expression{expr}}
+		for p.currTokenIs(lexer.COMMA) {
+			p.NextToken()
+			tuple.Items = append(tuple.Items, p.parseExpression(LOWEST))
+		}
+		expr = tuple
+	}
 	stmt.Expr = expr
 	return stmt
 }
@@ -631,13 +764,17 @@ func (p *Parser) parseBlockStatement() *BlockStatement {
 	bs := &BlockStatement{Token: p.currToken}
 	bs.Statements = []Statement{}
 
-	for !p.currTokenIs(lexer.RBRACE) {
+	// a block recovers from its own errors, even when the statement it belongs to already failed
+	outerPanicking := p.panicking
+	p.panicking = false
+	defer func() { p.panicking = p.panicking || outerPanicking }()
+
+	for !p.currTokenIs(lexer.RBRACE) && !p.currTokenIs(lexer.EOF) {
+		depth := p.braceDepth
 		stmt := p.parseStatement()
-		if stmt == nil {
-			break
-		}
-		if es, ok := stmt.(*ExpressionStatement); ok && es.Expression == nil {
-			break
+		if p.panicking || stmt == nil {
+			p.synchronize(depth)
+			continue
 		}
 		bs.Statements = append(bs.Statements, stmt)
 		if p.currTokenIs(lexer.SEMICOLON) {
@@ -659,14 +796,151 @@ func (p *Parser) getPointers(vt *lexer.VarType) {
 	}
 }
 
+// parseVarType parses a builtin or struct type along with any pointers, leaving curr on the token after it
+func (p *Parser) parseVarType() (lexer.VarType, bool) {
+	var vt lexer.VarType
+	if p.currTokenIs(lexer.QUESTION) {
+		p.NextToken() // past ?
+		inner, ok := p.parseVarType()
+		if !ok {
+			return vt, false
+		}
+		if inner.IsWrapped() {
+			p.appendError(&p.currToken.Position, diagnostics.CodeBadType, "optional types cannot wrap another optional or result type")
+			return vt, false
+		}
+		inner.Optional = true
+		return inner, true
+	}
+	if p.currTokenIs(lexer.TYPE) {
+		vt = p.currToken.VarType
+	} else if p.currTokenIs(lexer.IDENTIFIER) {
+		vt = lexer.VarType{
+			IsStructType: true,
+			StructName:   p.currToken.Literal,
+		}
+	} else {
+		return vt, false
+	}
+	p.NextToken()
+	p.getPointers(&vt)
+
+	// T!E, only taken as a result type when a type follows so it doesn't eat a ! in an expression
+	if p.currTokenIs(lexer.NOT) && (p.peekTokenIs(lexer.TYPE) || p.peekTokenIs(lexer.IDENTIFIER)) {
+		p.NextToken() // past !
+		errType, ok := p.parseVarType()
+		if !ok {
+			return vt, false
+		}
+		if errType.IsWrapped() {
+			p.appendError(&p.currToken.Position, diagnostics.CodeBadType, "error type of a result cannot be another optional or result type")
+			return vt, false
+		}
+		vt.ErrType = &errType
+	}
+
+	return vt, true
+}
+
+// parseTupleType parses the (int, int) return type list of a function with multiple return values
+func (p *Parser) parseTupleType() (lexer.VarType, bool) {
+	startPos := p.currToken.Position
+	p.NextToken() // past (
+	var tuple []lexer.VarType
+	for !p.currTokenIs(lexer.RPAREN) {
+		vt, ok := p.parseVarType()
+		if !ok {
+			p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected type in return type list")
+			return lexer.VarType{}, false
+		}
+		tuple = append(tuple, vt)
+		if p.currTokenIs(lexer.RPAREN) {
+			break
+		} else if p.currTokenIs(lexer.COMMA) {
+			p.NextToken()
+			continue
+		} else {
+			p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected , or ) in return type list, got %s", p.currToken.Type)
+			return lexer.VarType{}, false
+		}
+	}
+	startPos.CopyEnd(&p.currToken.Position)
+	p.NextToken() // past )
+	if len(tuple) < 2 {
+		p.appendError(&startPos, diagnostics.CodeBadType, "return type list should have at least two types, use a bare type for a single return value")
+		return lexer.VarType{}, false
+	}
+
+	return lexer.VarType{Tuple: tuple}, true
+}
+
 func (p *Parser) parseFunctionStatement() Statement {
+	stmt := p.parseFunctionSignature()
+	if stmt == nil {
+		return nil
+	}
+
+	if !p.expectCurr(lexer.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseBlockStatement()
+	currPos := p.currToken.Position
+	if !p.expectCurr(lexer.RBRACE) {
+		return nil
+	}
+	stmt.Position().CopyEnd(&currPos)
+
+	return stmt
+}
+
+// parseExternStatement parses declarations of symbols defined outside gl3, extern fnc f(int32 x) -> int32 or
+// extern global int32 x
+func (p *Parser) parseExternStatement() Statement {
+	externToken := p.currToken
+	p.NextToken() // past extern
+	switch p.currToken.Type {
+	case lexer.FNC:
+		stmt := p.parseFunctionSignature()
+		if stmt == nil {
+			return nil
+		}
+		stmt.Extern = true
+		stmt.position.StartCol = externToken.Position.StartCol
+		return stmt
+	case lexer.GLOBAL:
+		return p.parseDef(true)
+	}
+
+	p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected fnc or global after extern keyword, got %s", p.currToken.Type)
+	return nil
+}
+
+// parseExportStatement parses functions visible outside the library they're built into, export fnc f() -> int32 { }
+func (p *Parser) parseExportStatement() Statement {
+	exportToken := p.currToken
+	p.NextToken() // past export
+	if !p.currTokenIs(lexer.FNC) {
+		p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected fnc after export keyword, got %s", p.currToken.Type)
+		return nil
+	}
+	stmt, ok := p.parseFunctionStatement().(*FunctionStatement)
+	if !ok {
+		return nil
+	}
+	stmt.Exported = true
+	stmt.position.StartCol = exportToken.Position.StartCol
+	return stmt
+}
+
+// parseFunctionSignature parses everything of a function definition up to its body
+func (p *Parser) parseFunctionSignature() *FunctionStatement {
 	stmt := &FunctionStatement{Token: p.currToken, position: util.Position{
 		StartLine: p.currToken.Position.StartLine,
 		StartCol:  p.currToken.Position.EndCol,
 	}}
 	p.NextToken()
 	if !p.currTokenIs(lexer.IDENTIFIER) {
-		p.appendError(&p.currToken.Position, "expected identifier after fnc keyword")
+		p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected identifier after fnc keyword")
 		return nil
 	}
 	stmt.Name = &IdentifierExpression{Token: p.currToken, Value: p.currToken.Literal}
@@ -679,21 +953,22 @@ func (p *Parser) parseFunctionStatement() Statement {
 
 	// for empty arg list if it is rparen then it just stops immediately since we curr are on lparen
 	for !p.currTokenIs(lexer.RPAREN) {
-		var paramType lexer.VarType
-		if p.currTokenIs(lexer.TYPE) {
-			paramType = p.currToken.VarType
-		} else if p.currTokenIs(lexer.IDENTIFIER) {
-			paramType = lexer.VarType{
-				IsStructType: true,
-				StructName:   p.currToken.Literal,
+		if p.currTokenIs(lexer.ELLIPSIS) {
+			stmt.Variadic = true
+			p.NextToken()
+			if !p.currTokenIs(lexer.RPAREN) {
+				p.appendError(&p.currToken.Position, diagnostics.CodeBadDeclaration, "variadic ... must be the last parameter in function definition")
+				return nil
 			}
-		} else {
+			break
+		}
+		paramType, ok := p.parseVarType()
+		if !ok {
+			p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected type in function definition, got %s", p.currToken.Type)
 			return nil
 		}
-		p.NextToken()
-		p.getPointers(&paramType)
 		if !p.currTokenIs(lexer.IDENTIFIER) {
-			p.appendError(&p.currToken.Position, "expected identifier after type in function definition")
+			p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected identifier after type in function definition")
 			return nil
 		}
 		ident := &IdentifierExpression{Token: p.currToken, Value: p.currToken.Literal}
@@ -711,6 +986,7 @@ func (p *Parser) parseFunctionStatement() Statement {
 			p.NextToken()
 			continue
 		} else {
+			p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected , or ) in function definition, got %s", p.currToken.Type)
 			return nil
 		}
 	}
@@ -719,34 +995,32 @@ func (p *Parser) parseFunctionStatement() Statement {
 	if !p.expectCurr(lexer.ARROW) {
 		return nil
 	}
-	if p.currTokenIs(lexer.TYPE) {
-		stmt.Type = p.currToken.VarType
-	} else if p.currTokenIs(lexer.IDENTIFIER) {
-		stmt.Type = lexer.VarType{
-			IsStructType: true,
-			StructName:   p.currToken.Literal,
+	stmt.Position().CopyEnd(&p.currToken.Position)
+	if p.currTokenIs(lexer.LPAREN) {
+		tuple, ok := p.parseTupleType()
+		if !ok {
+			return nil
 		}
+		stmt.Type = tuple
 	} else {
-		return nil
-	}
-	p.NextToken()
-	p.getPointers(&stmt.Type)
-
-	if !p.expectCurr(lexer.LBRACE) {
-		return nil
-	}
-	stmt.Body = p.parseBlockStatement()
-	currPos := p.currToken.Position
-	if !p.expectCurr(lexer.RBRACE) {
-		return nil
+		retType, ok := p.parseVarType()
+		if !ok {
+			p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected return type after ->, got %s", p.currToken.Type)
+			return nil
+		}
+		stmt.Type = retType
 	}
-	stmt.Position().CopyEnd(&currPos)
 
 	return stmt
 }
 
-func (p *Parser) parseVarStatement() *DefStatement {
-	stmt := &DefStatement{Token: p.currToken}
+func (p *Parser) parseVarStatement() Statement {
+	return p.parseDef(false)
+}
+
+// parseDef parses def and global statements, extern globals having no initializer
+func (p *Parser) parseDef(extern bool) Statement {
+	stmt := &DefStatement{Token: p.currToken, Extern: extern}
 	if p.currTokenIs(lexer.GLOBAL) {
 		stmt.Global = true
 	}
@@ -756,31 +1030,71 @@ func (p *Parser) parseVarStatement() *DefStatement {
 			stmt.Constant = true
 			p.NextToken()
 		} else {
-			p.appendError(&p.currToken.Position, "const keyword can only be used with global variables")
+			p.appendError(&p.currToken.Position, diagnostics.CodeBadDeclaration, "const keyword can only be used with global variables")
 			return nil
 		}
 	}
-	if p.currTokenIs(lexer.TYPE) {
-		stmt.Type = p.currToken.VarType
-	} else if p.currTokenIs(lexer.IDENTIFIER) {
-		stmt.Type = lexer.VarType{
-			IsStructType: true,
-			StructName:   p.currToken.Literal,
-		}
-	} else {
+	vt, ok := p.parseVarType()
+	if !ok {
+		p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected type in def stmt, got %s", p.currToken.Type)
 		return nil
 	}
-	p.NextToken()
-	p.getPointers(&stmt.Type)
+	stmt.Type = vt
 
 	if !p.currTokenIs(lexer.IDENTIFIER) {
-		p.appendError(&p.currToken.Position, "expected identifier after type in def stmt")
+		p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected identifier after type in def stmt")
 		return nil
 	}
 
 	stmt.Name = &IdentifierExpression{Token: p.currToken, Value: p.currToken.Literal}
 	p.NextToken()
 
+	if stmt.Extern {
+		return stmt
+	}
+
+	if p.currTokenIs(lexer.COMMA) {
+		return p.parseDestructureStatement(stmt)
+	}
+
+	if !p.expectCurr(lexer.ASSIGN) {
+		return nil
+	}
+
+	stmt.Right = p.parseExpression(LOWEST)
+
+	return stmt
+}
+
+// parseDestructureStatement continues a def statement that turned out to have several targets, i.e
+// def int q, int r = divmod(x, y)
+func (p *Parser) parseDestructureStatement(first *DefStatement) Statement {
+	stmt := &DestructureStatement{
+		Token: first.Token,
+		Names: []*IdentifierExpression{first.Name},
+		Types: []lexer.VarType{first.Type},
+	}
+	if first.Global {
+		p.appendError(&first.Token.Position, diagnostics.CodeBadDeclaration, "global variables cannot be destructured")
+		return nil
+	}
+
+	for p.currTokenIs(lexer.COMMA) {
+		p.NextToken() // past ,
+		vt, ok := p.parseVarType()
+		if !ok {
+			p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected type in destructuring def stmt")
+			return nil
+		}
+		if !p.currTokenIs(lexer.IDENTIFIER) {
+			p.appendError(&p.currToken.Position, diagnostics.CodeSyntax, "expected identifier after type in destructuring def stmt")
+			return nil
+		}
+		stmt.Types = append(stmt.Types, vt)
+		stmt.Names = append(stmt.Names, &IdentifierExpression{Token: p.currToken, Value: p.currToken.Literal})
+		p.NextToken()
+	}
+
 	if !p.expectCurr(lexer.ASSIGN) {
 		return nil
 	}
@@ -817,7 +1131,7 @@ func (p *Parser) parseExpression(precendence byte) Expression {
 	}
 	leftExp := prefix()
 
-	for !p.peekTokenIs(lexer.SEMICOLON) && precendence < p.currPrecedence() {
+	for leftExp != nil && !p.peekTokenIs(lexer.SEMICOLON) && precendence < p.currPrecedence() {
 		infix := p.infixParseFns[p.currToken.Type]
 		if infix == nil {
 			return leftExp
@@ -847,6 +1161,18 @@ func (p *Parser) parseContinueStatement() Statement {
 	return stmt
 }
 
+func (p *Parser) parseDeferStatement() Statement {
+	stmt := &DeferStatement{Token: p.currToken}
+	p.NextToken() // past defer
+	stmt.Expr = p.parseExpression(LOWEST)
+	if stmt.Expr == nil {
+		p.appendError(&stmt.Token.Position, diagnostics.CodeSyntax, "expected expression after defer keyword")
+		return nil
+	}
+
+	return stmt
+}
+
 func (p *Parser) peekPrecedence() byte {
 	if p, ok := precedences[p.peekToken.Type]; ok {
 		return p
@@ -864,9 +1190,9 @@ func (p *Parser) currPrecedence() byte {
 }
 
 func (p *Parser) noPrefixParseFnError(t lexer.Token, pos *util.Position) {
-	p.appendError(pos, "no prefix parse function for %s, peek=%s found", t.Type.String(), p.peekToken.Type.String())
+	p.appendError(pos, diagnostics.CodeSyntax, "no prefix parse function for %s, peek=%s found", t.Type.String(), p.peekToken.Type.String())
 	// to prevent inf loops
-	p.NextToken()
+	p.skipUnexpected()
 }
 
 func (p *Parser) expectPeek(t lexer.TokenType) bool {
@@ -880,7 +1206,7 @@ func (p *Parser) expectPeek(t lexer.TokenType) bool {
 }
 
 func (p *Parser) peekError(t lexer.TokenType, pos *util.Position) {
-	p.appendError(pos, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
+	p.appendError(pos, diagnostics.CodeSyntax, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
 }
 
 func (p *Parser) expectCurr(t lexer.TokenType) bool {
@@ -891,17 +1217,37 @@ func (p *Parser) expectCurr(t lexer.TokenType) bool {
 
 	p.currError(t, &p.currToken.Position)
 	// advance so it can keep parsing instead of a loop or something
-	p.NextToken()
+	p.skipUnexpected()
 	return false
 }
 
 func (p *Parser) currError(t lexer.TokenType, pos *util.Position) {
-	p.appendError(pos, "expected curr token to be %s, got %s instead", t, p.peekToken.Type)
+	p.appendError(pos, diagnostics.CodeSyntax, "expected curr token to be %s, got %s instead", t, p.currToken.Type)
 }
 
-func (p *Parser) appendError(pos *util.Position, msg string, v ...any) {
+// skipUnexpected moves past a token that can't be parsed here, leaving braces for synchronize to balance and the
+// start of the next statement for synchronize to stop at
+func (p *Parser) skipUnexpected() {
+	if p.currTokenIs(lexer.LBRACE) || p.currTokenIs(lexer.RBRACE) || p.currTokenIs(lexer.EOF) {
+		return
+	}
+	if _, ok := statementStarts[p.currToken.Type]; ok {
+		return
+	}
+	p.NextToken()
+}
+
+func (p *Parser) appendError(pos *util.Position, code string, msg string, v ...any) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+	p.panicLine = pos.EndLine
+	// pos is often &p.currToken.Position, which moves on with the parser
+	errPos := *pos
 	p.Errors = append(p.Errors, util.PositionError{
-		Position: pos,
+		Position: &errPos,
+		Code:     code,
 		Msg:      fmt.Sprintf(msg, v...),
 	})
 }
@@ -45,6 +45,10 @@ func TestLiterals(t *testing.T) {
 			"4u64",
 			"4(Uint);",
 		},
+		"uint64 past int64": {
+			"18446744073709551615u64",
+			"18446744073709551615(Uint);",
+		},
 		"float": {
 			"1.5",
 			"1.5(Float);",
@@ -159,6 +163,10 @@ func TestFuncStatement(t *testing.T) {
 			"fnc x(int8 x, int32** other) -> int8 { \n return x; \n }",
 			"fnc x(Int8 x, Int32** other) -> Int8 { return x };",
 		},
+		"bare return": {
+			"fnc stop(bool b) -> none { \n if b { \n return \n } \n return; \n }",
+			"fnc stop(Bool b) -> Void { if b { return };return };",
+		},
 		"bool ret": {
 			"fnc isok() -> bool { \n return true; \n }",
 			"fnc isok() -> Bool { return true };",
@@ -181,6 +189,137 @@ func TestFuncStatement(t *testing.T) {
 	runTests(t, tests)
 }
 
+func TestMultipleReturnValues(t *testing.T) {
+	tests := map[string]InputOutput{
+		"tuple return type": {
+			"fnc divmod(int a, int b) -> (int, int) { \n return a / b, a - b \n }",
+			"fnc divmod(Int a, Int b) -> (Int, Int) { return (a / b), (a - b) };",
+		},
+		"tuple with pointers and structs": {
+			"fnc f() -> (Point*, char*, bool) { \n return p, s, true \n }",
+			"fnc f() -> (Point*, Char*, Bool) { return p, s, true };",
+		},
+		"destructure": {
+			"def int q, int r = divmod(x, y)",
+			"def Int q, Int r = divmod(x, y);",
+		},
+		"destructure three": {
+			"def int32 a, char* b, Point c = f()",
+			"def Int32 a, Char* b, Point c = f();",
+		},
+	}
+
+	runTests(t, tests)
+}
+
+func TestOptionalAndResult(t *testing.T) {
+	tests := map[string]InputOutput{
+		"optional def null": {
+			"def ?int32 x = null",
+			"def ?Int32 x = null;",
+		},
+		"optional pointer": {
+			"def ?char* s = some(name)",
+			"def ?Char* s = some(name);",
+		},
+		"ok as plain identifier": {
+			"def bool ok = err && some",
+			"def Bool ok = (err && some);",
+		},
+		"result def": {
+			"def int!int32 r = ok(5)",
+			"def Int!Int32 r = ok(5(Int));",
+		},
+		"result err": {
+			"def int32!char* r = err(msg)",
+			"def Int32!Char* r = err(msg);",
+		},
+		"optional return type": {
+			"fnc find(int32 x) -> ?Point* { \n return null \n }",
+			"fnc find(Int32 x) -> ?Point* { return null };",
+		},
+		"result param": {
+			"fnc f(int!char* r) -> int!char* { \n return r \n }",
+			"fnc f(Int!Char* r) -> Int!Char* { return r };",
+		},
+		"try prefix": {
+			"def int32 x = try parse(s)",
+			"def Int32 x = (try parse(s));",
+		},
+		"try postfix": {
+			"def int32 x = parse(s)? + 1i32",
+			"def Int32 x = (parse(s)? + 1(Int32));",
+		},
+		"orelse": {
+			"def int32 x = parse(s) orelse 0i32",
+			"def Int32 x = (parse(s) orelse 0(Int32));",
+		},
+		"orelse binds looser than or": {
+			"def bool x = a || b orelse false",
+			"def Bool x = ((a || b) orelse false);",
+		},
+		"cast to optional pointer then compare": {
+			"x as ?int* != y",
+			"(x as ?Int* != y);",
+		},
+	}
+
+	runTests(t, tests)
+}
+
+func TestVariadicFunction(t *testing.T) {
+	tests := map[string]InputOutput{
+		"trailing variadic": {
+			"fnc log(char* fmt, ...) -> none { \n def va_list args = va_start() \n }",
+			"fnc log(Char* fmt, ...) -> Void { def VaList args = va_start() };",
+		},
+		"only variadic": {
+			"fnc sum(...) -> int { \n return va_arg(args, sizeof int) \n }",
+			"fnc sum(...) -> Int { return va_arg(args, sizeof Int) };",
+		},
+	}
+
+	runTests(t, tests)
+}
+
+func TestExternStatement(t *testing.T) {
+	tests := map[string]InputOutput{
+		"extern fnc": {
+			"extern fnc puts(char* s) -> int32",
+			"extern fnc puts(Char* s) -> Int32;",
+		},
+		"extern variadic fnc": {
+			"extern fnc printf(char* fmt, ...) -> int32",
+			"extern fnc printf(Char* fmt, ...) -> Int32;",
+		},
+		"extern global": {
+			"extern global int32 opterr",
+			"extern global Int32 opterr;",
+		},
+		"extern then fnc": {
+			"extern fnc abs(int32 x) -> int32 \n fnc f() -> int32 { \n return abs(x) \n }",
+			"extern fnc abs(Int32 x) -> Int32;fnc f() -> Int32 { return abs(x) };",
+		},
+	}
+
+	runTests(t, tests)
+}
+
+func TestExportStatement(t *testing.T) {
+	tests := map[string]InputOutput{
+		"export fnc": {
+			"export fnc add(int32 a, int32 b) -> int32 { \n return a + b \n }",
+			"export fnc add(Int32 a, Int32 b) -> Int32 { return (a + b) };",
+		},
+		"export then fnc": {
+			"export fnc f() -> none { \n } \n fnc g() -> none { \n }",
+			"export fnc f() -> Void {  };fnc g() -> Void {  };",
+		},
+	}
+
+	runTests(t, tests)
+}
+
 func TestImportStatement(t *testing.T) {
 	tests := map[string]InputOutput{
 		"std module": {
@@ -266,6 +405,25 @@ func TestWhileStatement(t *testing.T) {
 	runTests(t, tests)
 }
 
+func TestDeferStatement(t *testing.T) {
+	tests := map[string]InputOutput{
+		"call": {
+			"defer str_free(s)",
+			"defer str_free(s);",
+		},
+		"inside function": {
+			"fnc main() -> int32 { \n def char* s = malloc(8)\n defer free(s)\n return 0i32 \n }",
+			"fnc main() -> Int32 { def Char* s = malloc(8(Int));defer free(s);return 0(Int32) };",
+		},
+		"inside while": {
+			"while true { \n defer free(p) \n break \n }",
+			"while true { defer free(p);break };",
+		},
+	}
+
+	runTests(t, tests)
+}
+
 func TestInfixExpression(t *testing.T) {
 	tests := map[string]InputOutput{
 		"plus": {
@@ -603,7 +761,7 @@ func TestStructStatement(t *testing.T) {
 				if idx < 0 || idx >= len(stmt.Types) {
 					t.Fatalf("field %q has invalid index %d", fieldName, idx)
 				}
-				if stmt.Types[idx] != expectedType {
+				if !stmt.Types[idx].Equals(expectedType) {
 					t.Fatalf("field %q expected type %s, got %s", fieldName, expectedType.String(), stmt.Types[idx].String())
 				}
 			}
@@ -631,6 +789,91 @@ fnc main() -> int32 {
 	runTestCheckForTimeout(t, tests)
 }
 
+func TestErrorRecovery(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		lines []uint32 // line of each error reported, in order
+	}{
+		"independent errors in one function": {
+			"fnc main() -> int32 {\n def int = 5\n println(\"x\")\n foo(1, 2\n def int z = )\n return 0i32\n}",
+			[]uint32{2, 5, 5},
+		},
+		"broken signature skips its body": {
+			"fnc add(int a, int b -> int {\n return a +\n}\n\nfnc main() -> int32 {\n def int = 1\n return 0i32\n}",
+			[]uint32{1, 6},
+		},
+		"block inside a broken statement": {
+			"fnc main() -> int32 {\n if x > {\n def int = 1\n }\n return 0i32\n}",
+			[]uint32{2, 3},
+		},
+		"broken struct": {
+			"struct P {\n int x\n 5 y\n}\n\nimport 5",
+			[]uint32{1, 6},
+		},
+		"stray closing brace": {
+			"}\nfnc main() -> int32 {\n return 0i32\n}\n}",
+			[]uint32{1, 5},
+		},
+		"operand missing before the next statement": {
+			"fnc main() -> int32 {\n def int y = 5 +\n def int z = )\n return 0i32\n}",
+			[]uint32{3, 3},
+		},
+		"unclosed block": {
+			"fnc main() -> int32 {\n def int x = 1",
+			[]uint32{2},
+		},
+		"negative literal before a call on the next line": {
+			"def int b = -1\nprint(\"x\")",
+			nil,
+		},
+		"literal before a call on the next line": {
+			"def int b = 1\nprint(\"x\")",
+			nil,
+		},
+		"calling a negated literal": {
+			"def int b = -1(2)\ndef int = 3",
+			[]uint32{1, 2},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			timeout := time.After(1 * time.Second)
+			done := make(chan bool)
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Errorf("panicked: %v", r)
+					}
+					done <- true
+				}()
+				p := New(lexer.New(test.input))
+				p.ParseProgram()
+				var lines []uint32
+				for _, err := range p.Errors {
+					lines = append(lines, err.Position.StartLine)
+				}
+				if len(lines) != len(test.lines) {
+					t.Errorf("wanted errors on lines %v, got %v: %v", test.lines, lines, p.Errors)
+					return
+				}
+				for i := range lines {
+					if lines[i] != test.lines[i] {
+						t.Errorf("wanted errors on lines %v, got %v: %v", test.lines, lines, p.Errors)
+						return
+					}
+				}
+			}()
+
+			select {
+			case <-timeout:
+				t.Fatalf("timed out after 1 second while parsing input")
+			case <-done:
+			}
+		})
+	}
+}
+
 func runTestCheckForTimeout(t *testing.T, tests map[string]string) {
 	for name, input := range tests {
 		t.Run(name, func(t *testing.T) {
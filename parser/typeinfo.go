@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"grianlang3/lexer"
+	"grianlang3/util"
+)
+
+// SymbolKind is the kind of declaration a name resolved to
+type SymbolKind uint8
+
+const (
+	VariableSymbol SymbolKind = iota + 1
+	ParameterSymbol
+	GlobalSymbol
+	FunctionSymbol
+	FieldSymbol
+)
+
+func (sk SymbolKind) String() string {
+	switch sk {
+	case VariableSymbol:
+		return "variable"
+	case ParameterSymbol:
+		return "parameter"
+	case GlobalSymbol:
+		return "global"
+	case FunctionSymbol:
+		return "function"
+	case FieldSymbol:
+		return "field"
+	default:
+		return "unknown"
+	}
+}
+
+// Symbol is a declaration as resolved by the checker
+type Symbol struct {
+	Kind     SymbolKind
+	Name     string
+	Type     lexer.VarType  // return type for functions
+	Position *util.Position // where it was declared, nil for names from imports and extern declarations
+
+	// functions
+	Params   []lexer.VarType
+	Variadic bool
+
+	// fields
+	Struct string
+	Index  int
+}
+
+// TypeInfo is the checker's side table for the AST, the resolved type of every expression it checked and the symbol
+// of every identifier, call and field access
+type TypeInfo struct {
+	Types   map[Expression]lexer.VarType
+	Symbols map[Expression]*Symbol
+}
+
+func NewTypeInfo() *TypeInfo {
+	return &TypeInfo{
+		Types:   make(map[Expression]lexer.VarType),
+		Symbols: make(map[Expression]*Symbol),
+	}
+}
+
+func (ti *TypeInfo) TypeOf(expr Expression) (lexer.VarType, bool) {
+	vt, ok := ti.Types[expr]
+	return vt, ok
+}
+
+func (ti *TypeInfo) SymbolOf(expr Expression) (*Symbol, bool) {
+	sym, ok := ti.Symbols[expr]
+	return sym, ok
+}
@@ -15,6 +15,14 @@ func (pe *Position) CopyEnd(other *Position) {
 }
 
 type PositionError struct {
+	Position *Position
+	Code     string // one of the codes in package diagnostics, so the error can be looked up with gl3 explain
+	Msg      string
+	Related  []Related
+}
+
+// Related is another place in the source a PositionError refers to, like an earlier definition of the same name
+type Related struct {
 	Position *Position
 	Msg      string
 }